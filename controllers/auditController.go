@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"net/http"
+	"runners/interfaces"
+	"runners/models"
+	"runners/responses"
+)
+
+type AuditController struct {
+	auditService interfaces.AuditService
+}
+
+func NewAuditController(auditService interfaces.AuditService) *AuditController {
+	return &AuditController{
+		auditService: auditService,
+	}
+}
+
+func (ac AuditController) GetAuditEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := &models.AuditParams{
+		EntityID: query.Get("entity_id"),
+		Actor:    query.Get("actor"),
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+		Limit:    query.Get("limit"),
+	}
+
+	response, responseErr := ac.auditService.GetAuditEntries(r.Context(), params)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, response)
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runners/models"
+	"strconv"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RunnersControllerTestSuit) TestAuditLog() {
+	t := suite.T()
+
+	adminToken := suite.login("admin", "admin")
+
+	runnersRequest, _ := http.NewRequest("GET", "/runner?country=France", nil)
+	runnersRequest.Header.Set("Token", adminToken)
+	runnersRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(runnersRecorder, runnersRequest)
+
+	require.Equal(t, http.StatusOK, runnersRecorder.Result().StatusCode)
+
+	var batch models.RunnersBatch
+	err := json.Unmarshal(runnersRecorder.Body.Bytes(), &batch)
+
+	require.NoError(t, err)
+	require.Len(t, batch.Runners, 1)
+
+	runner := batch.Runners[0]
+	originalAge := runner.Age
+
+	// The runner is restored afterwards so the other tests see the seed data
+	for i, age := range []int{originalAge + 1, originalAge} {
+		runner.Age = age
+		update, _ := json.Marshal(runner)
+		updateRequest, _ := http.NewRequest("PUT", "/runner", bytes.NewReader(update))
+		updateRequest.Header.Set("Token", adminToken)
+		updateRequest.Header.Set("X-Request-ID", "audit-test-"+strconv.Itoa(i))
+		updateRecorder := httptest.NewRecorder()
+		suite.router.ServeHTTP(updateRecorder, updateRequest)
+
+		require.Equal(t, http.StatusOK, updateRecorder.Result().StatusCode)
+		assert.Equal(t, "audit-test-"+strconv.Itoa(i), updateRecorder.Header().Get("X-Request-ID"))
+	}
+
+	auditRequest, _ := http.NewRequest("GET", "/audit?actor=admin&entity_id="+runner.ID, nil)
+	auditRequest.Header.Set("Token", adminToken)
+	auditRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(auditRecorder, auditRequest)
+
+	require.Equal(t, http.StatusOK, auditRecorder.Result().StatusCode)
+
+	var entries []*models.AuditEntry
+	err = json.Unmarshal(auditRecorder.Body.Bytes(), &entries)
+
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// Newest entries come first
+	assert.Equal(t, "audit-test-1", entries[0].RequestID)
+	assert.JSONEq(t, `{"age": `+strconv.Itoa(originalAge+1)+`}`, string(entries[0].Before))
+	assert.JSONEq(t, `{"age": `+strconv.Itoa(originalAge)+`}`, string(entries[0].After))
+
+	assert.Equal(t, "audit-test-0", entries[1].RequestID)
+	assert.Equal(t, models.AUDIT_ACTION_UPDATE, entries[1].Action)
+	assert.Equal(t, models.AUDIT_ENTITY_RUNNER, entries[1].Entity)
+	assert.Equal(t, "admin", entries[1].Actor)
+	assert.NotEmpty(t, entries[1].ActorID)
+
+	userToken := suite.login("user", "user")
+
+	forbiddenRequest, _ := http.NewRequest("GET", "/audit", nil)
+	forbiddenRequest.Header.Set("Token", userToken)
+	forbiddenRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(forbiddenRecorder, forbiddenRequest)
+
+	assert.Equal(t, http.StatusForbidden, forbiddenRecorder.Result().StatusCode)
+}
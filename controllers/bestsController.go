@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"net/http"
+	"runners/interfaces"
+	"runners/responses"
+)
+
+type BestsController struct {
+	bestsService interfaces.BestsService
+}
+
+func NewBestsController(bestsService interfaces.BestsService) *BestsController {
+	return &BestsController{
+		bestsService: bestsService,
+	}
+}
+
+// RecomputeBests answers 202 right away, the rebuild is followed with
+// GetBestsRecomputation.
+func (bc BestsController) RecomputeBests(w http.ResponseWriter, r *http.Request) {
+	response, responseErr := bc.bestsService.StartRecomputeAllBests(r.Context())
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.Header().Set("Location", "/admin/recompute-bests/"+response.ID)
+	responses.WriteJSON(w, r, http.StatusAccepted, response)
+}
+
+func (bc BestsController) GetBestsRecomputation(w http.ResponseWriter, r *http.Request) {
+	response, responseErr := bc.bestsService.GetBestsRecomputation(r.Context(), r.PathValue("id"))
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, response)
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the addresses of the load balancers and proxies in front
+// of the app. Only they are trusted to set X-Forwarded-For.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses IP addresses and CIDR ranges, e.g. 10.0.0.0/8.
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(values))
+
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, expected IP address or CIDR range", value)
+			}
+
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+func (tp TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range tp {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the address of the direct peer unless it is a trusted
+// proxy. Then X-Forwarded-For is followed from the right to the first hop
+// that is not a trusted proxy, as all hops left of it can be forged.
+func (tp TrustedProxies) clientIP(r *http.Request) string {
+	remoteAddr, err := netip.ParseAddrPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	client := remoteAddr.Addr().Unmap()
+
+	if !tp.contains(client) {
+		return client.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+
+		if err != nil {
+			break
+		}
+
+		client = hop.Unmap()
+
+		if !tp.contains(client) {
+			break
+		}
+	}
+
+	return client.String()
+}
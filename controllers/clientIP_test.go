@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+
+	require.NoError(t, err)
+
+	request := func(remoteAddr string, forwardedFor string) *http.Request {
+		r, _ := http.NewRequest("POST", "/login", nil)
+		r.RemoteAddr = remoteAddr
+
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+
+		return r
+	}
+
+	// Untrusted peers can not forge their address
+	assert.Equal(t, "203.0.113.7", trustedProxies.clientIP(request("203.0.113.7:51234", "198.51.100.1")))
+	assert.Equal(t, "203.0.113.7", TrustedProxies(nil).clientIP(request("203.0.113.7:51234", "198.51.100.1")))
+
+	// Behind proxies the right-most untrusted hop is the client
+	assert.Equal(t, "198.51.100.2", trustedProxies.clientIP(request("10.1.2.3:443", "198.51.100.1, 198.51.100.2, 192.168.1.1")))
+	assert.Equal(t, "10.1.2.3", trustedProxies.clientIP(request("10.1.2.3:443", "")))
+	assert.Equal(t, "10.4.0.1", trustedProxies.clientIP(request("10.1.2.3:443", "forged, 10.4.0.1")))
+
+	_, err = ParseTrustedProxies([]string{"proxy.local"})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runners/interfaces"
+	"runners/models"
+	"runners/responses"
+)
+
+type EventsController struct {
+	eventsService interfaces.EventsService
+}
+
+func NewEventsController(eventsService interfaces.EventsService) *EventsController {
+	return &EventsController{
+		eventsService: eventsService,
+	}
+}
+
+func (ec EventsController) CreateEvent(w http.ResponseWriter, r *http.Request) {
+	var event models.Event
+	err := json.NewDecoder(r.Body).Decode(&event)
+
+	if err != nil {
+		responses.WriteError(w, r, responses.BadRequestBody(err))
+		return
+	}
+
+	response, responseErr := ec.eventsService.CreateEvent(r.Context(), &event)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, response)
+}
+
+func (ec EventsController) UpdateEvent(w http.ResponseWriter, r *http.Request) {
+	var event models.Event
+	err := json.NewDecoder(r.Body).Decode(&event)
+
+	if err != nil {
+		responses.WriteError(w, r, responses.BadRequestBody(err))
+		return
+	}
+
+	event.ID = r.PathValue("id")
+	responseErr := ec.eventsService.UpdateEvent(r.Context(), &event)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ec EventsController) DeleteEvent(w http.ResponseWriter, r *http.Request) {
+	eventId := r.PathValue("id")
+	responseErr := ec.eventsService.DeleteEvent(r.Context(), eventId)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ec EventsController) GetEvent(w http.ResponseWriter, r *http.Request) {
+	eventId := r.PathValue("id")
+
+	event, responseErr := ec.eventsService.GetEvent(r.Context(), eventId)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	if event == nil {
+		responses.WriteError(w, r, eventNotFound())
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, event)
+}
+
+func (ec EventsController) GetEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := &models.EventsParams{
+		Year:    query.Get("year"),
+		Country: query.Get("country"),
+	}
+
+	response, responseErr := ec.eventsService.GetEvents(r.Context(), params)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, response)
+}
+
+func (ec EventsController) CreateRace(w http.ResponseWriter, r *http.Request) {
+	var race models.Race
+	err := json.NewDecoder(r.Body).Decode(&race)
+
+	if err != nil {
+		responses.WriteError(w, r, responses.BadRequestBody(err))
+		return
+	}
+
+	race.EventID = r.PathValue("id")
+	response, responseErr := ec.eventsService.CreateRace(r.Context(), &race)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, response)
+}
+
+func (ec EventsController) UpdateRace(w http.ResponseWriter, r *http.Request) {
+	var race models.Race
+	err := json.NewDecoder(r.Body).Decode(&race)
+
+	if err != nil {
+		responses.WriteError(w, r, responses.BadRequestBody(err))
+		return
+	}
+
+	race.EventID = r.PathValue("id")
+	race.ID = r.PathValue("raceId")
+	responseErr := ec.eventsService.UpdateRace(r.Context(), &race)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ec EventsController) DeleteRace(w http.ResponseWriter, r *http.Request) {
+	responseErr := ec.eventsService.DeleteRace(r.Context(), r.PathValue("id"), r.PathValue("raceId"))
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ec EventsController) GetRaces(w http.ResponseWriter, r *http.Request) {
+	eventId := r.PathValue("id")
+
+	races, responseErr := ec.eventsService.GetRaces(r.Context(), eventId)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	if races == nil {
+		responses.WriteError(w, r, eventNotFound())
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, races)
+}
+
+func eventNotFound() *models.ResponseError {
+	return &models.ResponseError{
+		Message: "Event not found",
+		Status:  http.StatusNotFound,
+		Code:    models.ERROR_CODE_EVENT_NOT_FOUND,
+	}
+}
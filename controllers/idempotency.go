@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"runners/models"
+	"runners/responses"
+)
+
+// idempotencyKey returns the Idempotency-Key of the request to route with
+// the hash of its decoded body, nil if the header is not set. The body is
+// encoded again before hashing, so key order and whitespace do not matter.
+func idempotencyKey(r *http.Request, route string, body any) (*models.IdempotencyKey, *models.ResponseError) {
+	key := r.Header.Get("Idempotency-Key")
+
+	if key == "" {
+		return nil, nil
+	}
+
+	if len(key) > models.MAX_IDEMPOTENCY_KEY_LENGTH {
+		return nil, &models.ResponseError{
+			Message: "Idempotency-Key is too long",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_IDEMPOTENCY_KEY,
+		}
+	}
+
+	canonical, err := json.Marshal(body)
+
+	if err != nil {
+		return nil, responses.BadRequestBody(err)
+	}
+
+	hash := sha256.Sum256(canonical)
+
+	return &models.IdempotencyKey{
+		UserID:      models.PrincipalFromContext(r.Context()).UserID,
+		Route:       route,
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// idempotentStatus marks responses that are replayed for a key and returns
+// the status they were first sent with, status otherwise.
+func idempotentStatus(w http.ResponseWriter, key *models.IdempotencyKey, status int) int {
+	if key == nil || !key.Replayed {
+		return status
+	}
+
+	w.Header().Set("Idempotent-Replayed", "true")
+
+	return key.Status
+}
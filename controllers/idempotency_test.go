@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runners/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeyHashesDecodedBody(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/runner", nil)
+	request = request.WithContext(models.ContextWithPrincipal(context.Background(), &models.Principal{UserID: "e5280c8b-093d-457a-a535-2127326cd1b2"}))
+	request.Header.Set("Idempotency-Key", "8f3c1e52-create-runner")
+
+	requestHash := func(body string) string {
+		var runner models.Runner
+		err := json.Unmarshal([]byte(body), &runner)
+
+		require.NoError(t, err)
+
+		key, responseErr := idempotencyKey(request, models.IDEMPOTENCY_ROUTE_CREATE_RUNNER, &runner)
+
+		require.Nil(t, responseErr)
+
+		return key.RequestHash
+	}
+
+	hash := requestHash(`{"first_name": "Sifan", "last_name": "Hassan", "age": 31, "country": "Netherlands"}`)
+
+	assert.Equal(t, hash, requestHash(`{"country":"Netherlands","age":31,"last_name":"Hassan","first_name":"Sifan"}`))
+	assert.NotEqual(t, hash, requestHash(`{"first_name": "Sifan", "last_name": "Hassan", "age": 32, "country": "Netherlands"}`))
+}
+
+func TestIdempotentStatus(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	assert.Equal(t, http.StatusOK, idempotentStatus(recorder, nil, http.StatusOK))
+	assert.Empty(t, recorder.Header().Get("Idempotent-Replayed"))
+
+	assert.Equal(t, http.StatusCreated, idempotentStatus(recorder, &models.IdempotencyKey{Replayed: true, Status: http.StatusCreated}, http.StatusOK))
+	assert.Equal(t, "true", recorder.Header().Get("Idempotent-Replayed"))
+}
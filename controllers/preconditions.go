@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http"
+	"runners/models"
+	"strconv"
+	"strings"
+)
+
+// etag formats version as the strong entity tag of a runner or result.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion returns the version given as single entity tag in If-Match,
+// ANY_VERSION for "*". Requests without If-Match fail with 428.
+func ifMatchVersion(r *http.Request) (int, *models.ResponseError) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	if value == "" {
+		return 0, &models.ResponseError{
+			Message: "If-Match header is required",
+			Status:  http.StatusPreconditionRequired,
+			Code:    models.ERROR_CODE_PRECONDITION_REQUIRED,
+		}
+	}
+
+	if value == "*" {
+		return models.ANY_VERSION, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`))
+
+	if err != nil || version < 1 || etag(version) != value {
+		return 0, &models.ResponseError{
+			Message: "Version does not match, reload and try again",
+			Status:  http.StatusPreconditionFailed,
+			Code:    models.ERROR_CODE_PRECONDITION_FAILED,
+		}
+	}
+
+	return version, nil
+}
+
+// notModified reports if If-None-Match names the current version, using the
+// weak comparison.
+func notModified(r *http.Request, version int) bool {
+	value := r.Header.Get("If-None-Match")
+
+	if strings.TrimSpace(value) == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(value, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag(version) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -4,98 +4,72 @@ import (
 	"encoding/json"
 	"net/http"
 	"runners/interfaces"
-	"runners/metrics"
-	"runners/middleware"
 	"runners/models"
-	"strconv"
+	"runners/responses"
 )
 
 type ResultsController struct {
 	resultsService interfaces.ResultsServiceInterface
-	usersService   interfaces.UsersService
 }
 
-func NewResultsController(resultsService interfaces.ResultsServiceInterface, usersService interfaces.UsersService) *ResultsController {
+func NewResultsController(resultsService interfaces.ResultsServiceInterface) *ResultsController {
 	return &ResultsController{
 		resultsService: resultsService,
-		usersService:   usersService,
 	}
 }
 
 func (rc ResultsController) CreateResult(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN})
-
-	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
-		return
-	}
-
 	var result models.Result
 	err := json.NewDecoder(r.Body).Decode(&result)
 
 	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responses.WriteError(w, r, responses.BadRequestBody(err))
 		return
 	}
 
-	response, responseErr := rc.resultsService.CreateResult(&result)
+	idempotencyKey, responseErr := idempotencyKey(r, models.IDEMPOTENCY_ROUTE_CREATE_RESULT, &result)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	responseJson, err := json.Marshal(response)
+	response, responseErr := rc.resultsService.CreateResult(r.Context(), &result, idempotencyKey)
 
-	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(responseJson)
+	status := idempotentStatus(w, idempotencyKey, http.StatusOK)
+	w.Header().Set("ETag", etag(response.Version))
+	responses.WriteJSON(w, r, status, response)
 }
 
 func (rc ResultsController) DeleteResult(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN})
+	version, responseErr := ifMatchVersion(r)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
 	resultId := r.PathValue("id")
-	responseErr = rc.resultsService.DeleteResult(resultId)
+	responseErr = rc.resultsService.DeleteResult(r.Context(), resultId, version)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("204").Inc()
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (rc ResultsController) UpdateResult(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN})
+	version, responseErr := ifMatchVersion(r)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
@@ -103,19 +77,19 @@ func (rc ResultsController) UpdateResult(w http.ResponseWriter, r *http.Request)
 	err := json.NewDecoder(r.Body).Decode(&result)
 
 	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responses.WriteError(w, r, responses.BadRequestBody(err))
 		return
 	}
 
-	responseErr = rc.resultsService.UpdateResult(&result)
+	result.ID = r.PathValue("id")
+	result.Version = version
+	response, responseErr := rc.resultsService.UpdateResult(r.Context(), &result)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("ETag", etag(response.Version))
+	responses.WriteJSON(w, r, http.StatusOK, response)
 }
@@ -4,75 +4,54 @@ import (
 	"encoding/json"
 	"net/http"
 	"runners/interfaces"
-	"runners/metrics"
-	"runners/middleware"
 	"runners/models"
+	"runners/responses"
 	"strconv"
 )
 
 type RunnersController struct {
 	runnersService interfaces.RunnersService
-	usersService   interfaces.UsersService
 }
 
-func NewRunnersController(runnersService interfaces.RunnersService, usersService interfaces.UsersService) *RunnersController {
+func NewRunnersController(runnersService interfaces.RunnersService) *RunnersController {
 	return &RunnersController{
 		runnersService: runnersService,
-		usersService:   usersService,
 	}
 }
 
 func (rc RunnersController) CreateRunner(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN})
-
-	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status))
-		http.Error(w, responseErr.Message, responseErr.Status)
-		return
-	}
-
 	var runner models.Runner
 	err := json.NewDecoder(r.Body).Decode(&runner)
 
 	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, "Error while reading request body", http.StatusInternalServerError)
+		responses.WriteError(w, r, responses.BadRequestBody(err))
 		return
 	}
 
-	response, responseErr := rc.runnersService.CreateRunner(&runner)
+	idempotencyKey, responseErr := idempotencyKey(r, models.IDEMPOTENCY_ROUTE_CREATE_RUNNER, &runner)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	responseJson, err := json.Marshal(response)
+	response, responseErr := rc.runnersService.CreateRunner(r.Context(), &runner, idempotencyKey)
 
-	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(responseJson)
+	status := idempotentStatus(w, idempotencyKey, http.StatusOK)
+	w.Header().Set("ETag", etag(response.Version))
+	responses.WriteJSON(w, r, status, response)
 }
 
 func (rc RunnersController) UpdateRunner(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN})
+	version, responseErr := ifMatchVersion(r)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status))
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
@@ -80,143 +59,164 @@ func (rc RunnersController) UpdateRunner(w http.ResponseWriter, r *http.Request)
 	err := json.NewDecoder(r.Body).Decode(&runner)
 
 	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, "Error while reading request body", http.StatusInternalServerError)
+		responses.WriteError(w, r, responses.BadRequestBody(err))
 		return
 	}
 
-	rowsAffected, responseErr := rc.runnersService.UpdateRunner(&runner)
+	runner.Version = version
+	rowsAffected, responseErr := rc.runnersService.UpdateRunner(r.Context(), &runner)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
 	if rowsAffected == 0 {
-		metrics.HttpResponsesCounter.WithLabelValues("404").Inc()
-		http.Error(w, "Runner not found", 404)
+		responses.WriteError(w, r, &models.ResponseError{
+			Message: "Runner not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_RUNNER_NOT_FOUND,
+		})
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
+	w.Header().Set("ETag", etag(runner.Version))
 	w.WriteHeader(http.StatusOK)
 }
 
-func (rc RunnersController) DeleteRunner(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
+func (rc RunnersController) DeactivateRunner(w http.ResponseWriter, r *http.Request) {
+	version, responseErr := ifMatchVersion(r)
 
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN})
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	runner, responseErr := rc.runnersService.DeactivateRunner(r.Context(), r.PathValue("id"), version)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status))
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	runnerId := r.PathValue("id")
+	w.Header().Set("ETag", etag(runner.Version))
+	responses.WriteJSON(w, r, http.StatusOK, runner)
+}
 
-	rowsAffected, responseErr := rc.runnersService.DeleteRunner(runnerId)
+func (rc RunnersController) ReactivateRunner(w http.ResponseWriter, r *http.Request) {
+	version, responseErr := ifMatchVersion(r)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	if rowsAffected == 0 {
-		metrics.HttpResponsesCounter.WithLabelValues("404").Inc()
-		http.Error(w, "Runner not found", 404)
+	runner, responseErr := rc.runnersService.ReactivateRunner(r.Context(), r.PathValue("id"), version)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("ETag", etag(runner.Version))
+	responses.WriteJSON(w, r, http.StatusOK, runner)
 }
 
-func (rc RunnersController) GetRunner(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN, ROLE_USER})
+func (rc RunnersController) DeleteRunner(w http.ResponseWriter, r *http.Request) {
+	version, responseErr := ifMatchVersion(r)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status))
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
+	principal := models.PrincipalFromContext(r.Context())
 	runnerId := r.PathValue("id")
+	params := &models.RunnerDeleteParams{
+		Purge:   r.URL.Query().Get("purge"),
+		Version: version,
+	}
 
-	runner, responseErr := rc.runnersService.GetRunner(runnerId)
+	responseErr = rc.runnersService.DeleteRunner(r.Context(), principal, runnerId, params)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	if runner == nil {
-		metrics.HttpResponsesCounter.WithLabelValues("404").Inc()
-		http.Error(w, "Runner not found", 404)
-		return
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rc RunnersController) GetRunner(w http.ResponseWriter, r *http.Request) {
+	runnerId := r.PathValue("id")
+	query := r.URL.Query()
+	runnerParams := &models.RunnerParams{
+		IncludeInactive: query.Get("include_inactive"),
 	}
 
-	runnersResults, responseErr := rc.runnersService.GetRunnersResults(runner.ID)
+	runner, responseErr := rc.runnersService.GetRunner(r.Context(), runnerId, runnerParams)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	runner.Results = runnersResults
-
-	responseJson, err := json.Marshal(runner)
-
-	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if runner == nil {
+		responses.WriteError(w, r, &models.ResponseError{
+			Message: "Runner not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_RUNNER_NOT_FOUND,
+		})
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(responseJson)
-}
-
-func (rc RunnersController) GetRunnersBatch(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
-	responseErr := middleware.AuthorizeRequest(r, rc.usersService, []string{ROLE_ADMIN, ROLE_USER})
+	w.Header().Set("ETag", etag(runner.Version))
 
-	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status))
-		http.Error(w, responseErr.Message, responseErr.Status)
+	if notModified(r, runner.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	country := r.URL.Query().Get("country")
-	year := r.URL.Query().Get("year")
+	params := &models.ResultsParams{
+		Status:          query.Get("status"),
+		IncludeInactive: runnerParams.IncludeInactive,
+	}
 
-	response, responseErr := rc.runnersService.GetRunnersBatch(country, year)
+	runnersResults, responseErr := rc.runnersService.GetRunnersResults(r.Context(), runner.ID, params)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	responseJson, err := json.Marshal(response)
+	runner.Results = runnersResults
 
-	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	responses.WriteJSON(w, r, http.StatusOK, runner)
+}
+
+func (rc RunnersController) GetRunnersBatch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := &models.RunnersBatchParams{
+		Country:         query.Get("country"),
+		Year:            query.Get("year"),
+		IsActive:        query.Get("is_active"),
+		IncludeInactive: query.Get("include_inactive"),
+		MinAge:          query.Get("min_age"),
+		MaxAge:          query.Get("max_age"),
+		Distance:        query.Get("distance"),
+		SortBy:          query.Get("sort"),
+		Order:           query.Get("order"),
+		Limit:           query.Get("limit"),
+		Cursor:          query.Get("cursor"),
+	}
+
+	response, responseErr := rc.runnersService.GetRunnersBatch(r.Context(), params)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(responseJson)
+	w.Header().Set("X-Total-Count", strconv.Itoa(response.TotalCount))
+	responses.WriteJSON(w, r, http.StatusOK, response)
 }
@@ -4,14 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"runners/middleware"
 	"runners/models"
 	"runners/repositories"
+	"runners/responses"
 	"runners/services"
 	"runners/testhelpers"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -24,7 +29,8 @@ import (
 type RunnersControllerTestSuit struct {
 	suite.Suite
 	pgContainer *testhelpers.PostgresContainer
-	router      *http.ServeMux
+	dbHandler   *sql.DB
+	router      http.Handler
 	ctx         context.Context
 }
 
@@ -49,6 +55,7 @@ func (suite *RunnersControllerTestSuit) SetupSuite() {
 		log.Fatal(err)
 	}
 
+	suite.dbHandler = dbHandler
 	suite.router = initTestRouter(dbHandler)
 }
 
@@ -59,24 +66,47 @@ func (suite *RunnersControllerTestSuit) TearDownSuite() {
 	}
 }
 
-func initTestRouter(dbHandler *sql.DB) *http.ServeMux {
+var testTokenIssuer, _ = services.NewTokenIssuer("HS256", "test-signing-key-of-at-least-32-bytes", time.Minute, time.Hour)
+
+var testSeason = models.Season{StartMonth: time.January, StartDay: 1}
+
+func initTestRouter(dbHandler *sql.DB) http.Handler {
 	runnersRepository := repositories.NewRunnersRepository(dbHandler)
 	usersRepository := repositories.NewUsersRepository(dbHandler)
-	runnersService := services.NewRunnersService(runnersRepository, nil)
-	usersService := services.NewUsersService(usersRepository)
-	runnersController := NewRunnersController(runnersService, usersService)
-	usersController := NewUsersController(usersService)
+	sessionsRepository := repositories.NewSessionsRepository(dbHandler)
+	unitOfWork := repositories.NewUnitOfWork(dbHandler)
+	runnersService := services.NewRunnersService(runnersRepository, repositories.NewResultsRepository(dbHandler), unitOfWork, testSeason, time.Hour)
+	usersService := services.NewUsersService(usersRepository, sessionsRepository, unitOfWork, testTokenIssuer)
+	runnersController := NewRunnersController(runnersService)
+	usersController := NewUsersController(usersService, nil)
+	resultsController := NewResultsController(services.NewResultsService(unitOfWork, testSeason, time.Hour))
+	auditController := NewAuditController(services.NewAuditService(repositories.NewAuditRepository(dbHandler)))
+	authorizer := middleware.NewAuthorizer(usersService)
 
 	router := http.NewServeMux()
-	router.HandleFunc("POST /runner", runnersController.CreateRunner)
-	router.HandleFunc("PUT /runner", runnersController.UpdateRunner)
-	router.HandleFunc("DELETE /runner/{id}", runnersController.DeleteRunner)
-	router.HandleFunc("GET /runner/{id}", runnersController.GetRunner)
-	router.HandleFunc("GET /runner", runnersController.GetRunnersBatch)
+	router.Handle("POST /runner", authorizer.Protect(models.PERMISSION_RUNNERS_WRITE, runnersController.CreateRunner))
+	router.Handle("PUT /runner", authorizer.Protect(models.PERMISSION_RUNNERS_WRITE, runnersController.UpdateRunner))
+	router.Handle("DELETE /runner/{id}", authorizer.Protect(models.PERMISSION_RUNNERS_DELETE, runnersController.DeleteRunner))
+	router.Handle("POST /runner/{id}/deactivate", authorizer.Protect(models.PERMISSION_RUNNERS_DELETE, runnersController.DeactivateRunner))
+	router.Handle("POST /runner/{id}/reactivate", authorizer.Protect(models.PERMISSION_RUNNERS_WRITE, runnersController.ReactivateRunner))
+	router.Handle("GET /runner/{id}", authorizer.Protect(models.PERMISSION_RUNNERS_READ, runnersController.GetRunner))
+	router.Handle("GET /runner", authorizer.Protect(models.PERMISSION_RUNNERS_READ, runnersController.GetRunnersBatch))
+
+	router.Handle("POST /result", authorizer.Protect(models.PERMISSION_RESULTS_WRITE, resultsController.CreateResult))
+	router.Handle("PUT /result/{id}", authorizer.Protect(models.PERMISSION_RESULTS_WRITE, resultsController.UpdateResult))
 
 	router.HandleFunc("POST /login", usersController.Login)
+	router.HandleFunc("POST /token/refresh", usersController.RefreshToken)
+	router.Handle("POST /logout", authorizer.Protect("", usersController.Logout))
+	router.Handle("GET /me/sessions", authorizer.Protect("", usersController.GetSessions))
+	router.Handle("PUT /me/password", authorizer.Protect("", usersController.ChangePassword))
+	router.Handle("POST /user", authorizer.Protect(models.PERMISSION_USERS_WRITE, usersController.CreateUser))
+	router.Handle("PUT /user/{id}", authorizer.Protect(models.PERMISSION_USERS_WRITE, usersController.UpdateUser))
+	router.Handle("DELETE /user/{id}", authorizer.Protect(models.PERMISSION_USERS_DELETE, usersController.DeleteUser))
 
-	return router
+	router.Handle("GET /audit", authorizer.Protect(models.PERMISSION_AUDIT_READ, auditController.GetAuditEntries))
+
+	return middleware.Chain(router, middleware.RequestID)
 }
 
 func (suite *RunnersControllerTestSuit) TestGetRunnersResponse() {
@@ -99,37 +129,691 @@ func (suite *RunnersControllerTestSuit) TestGetRunnersResponse() {
 
 	assert.Equal(t, http.StatusOK, recorder.Result().StatusCode)
 
-	var runners []*models.Runner
-	err := json.Unmarshal(recorder.Body.Bytes(), &runners)
+	var batch models.RunnersBatch
+	err := json.Unmarshal(recorder.Body.Bytes(), &batch)
+
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, batch.Runners)
+	assert.Equal(t, 4, len(batch.Runners))
+	assert.Empty(t, batch.NextCursor)
+	assert.Equal(t, "4", recorder.Header().Get("X-Total-Count"))
+}
+
+func (suite *RunnersControllerTestSuit) TestGetRunnersPaginated() {
+	t := suite.T()
+
+	loginRequest, _ := http.NewRequest("POST", "/login", nil)
+	loginRequest.SetBasicAuth("user", "user")
+	loginRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(loginRecorder, loginRequest)
+
+	require.Equal(t, http.StatusOK, loginRecorder.Result().StatusCode)
+
+	token := loginRecorder.Header().Get("Token")
+
+	var lastNames []string
+	cursor := ""
+
+	for page := 0; page < 2; page++ {
+		request, _ := http.NewRequest("GET", "/runner?sort=last_name&limit=3&cursor="+cursor, nil)
+		recorder := httptest.NewRecorder()
+
+		request.Header.Set("Token", token)
+		suite.router.ServeHTTP(recorder, request)
+
+		require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+
+		var batch models.RunnersBatch
+		err := json.Unmarshal(recorder.Body.Bytes(), &batch)
+
+		require.NoError(t, err)
+
+		for _, runner := range batch.Runners {
+			lastNames = append(lastNames, runner.LastName)
+		}
+
+		cursor = batch.NextCursor
+	}
+
+	assert.Equal(t, []string{"Mueller", "Petit", "Smith", "Smith"}, lastNames)
+	assert.Empty(t, cursor)
+}
+
+func (suite *RunnersControllerTestSuit) TestRefreshTokenRotation() {
+	t := suite.T()
+
+	loginRequest, _ := http.NewRequest("POST", "/login", nil)
+	loginRequest.SetBasicAuth("user", "user")
+	loginRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(loginRecorder, loginRequest)
+
+	require.Equal(t, http.StatusOK, loginRecorder.Result().StatusCode)
+
+	refreshToken := loginRecorder.Header().Get("Refresh-Token")
+
+	refreshRequest, _ := http.NewRequest("POST", "/token/refresh", nil)
+	refreshRequest.Header.Set("Refresh-Token", refreshToken)
+	refreshRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(refreshRecorder, refreshRequest)
+
+	require.Equal(t, http.StatusOK, refreshRecorder.Result().StatusCode)
+
+	rotatedToken := refreshRecorder.Header().Get("Refresh-Token")
+
+	assert.NotEmpty(t, refreshRecorder.Header().Get("Token"))
+	assert.NotEqual(t, refreshToken, rotatedToken)
+
+	// Reusing the first refresh token revokes the rotated one as well
+	reuseRequest, _ := http.NewRequest("POST", "/token/refresh", nil)
+	reuseRequest.Header.Set("Refresh-Token", refreshToken)
+	reuseRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(reuseRecorder, reuseRequest)
+
+	assert.Equal(t, http.StatusUnauthorized, reuseRecorder.Result().StatusCode)
+
+	rotatedRequest, _ := http.NewRequest("POST", "/token/refresh", nil)
+	rotatedRequest.Header.Set("Refresh-Token", rotatedToken)
+	rotatedRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(rotatedRecorder, rotatedRequest)
+
+	assert.Equal(t, http.StatusUnauthorized, rotatedRecorder.Result().StatusCode)
+}
+
+func (suite *RunnersControllerTestSuit) TestLogoutRevokesOnlyCurrentSession() {
+	t := suite.T()
+
+	tokens := make([]string, 2)
+
+	for i := range tokens {
+		loginRequest, _ := http.NewRequest("POST", "/login", nil)
+		loginRequest.SetBasicAuth("admin", "admin")
+		loginRecorder := httptest.NewRecorder()
+		suite.router.ServeHTTP(loginRecorder, loginRequest)
+
+		require.Equal(t, http.StatusOK, loginRecorder.Result().StatusCode)
+
+		tokens[i] = loginRecorder.Header().Get("Token")
+	}
+
+	logoutRequest, _ := http.NewRequest("POST", "/logout", nil)
+	logoutRequest.Header.Set("Token", tokens[0])
+	logoutRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(logoutRecorder, logoutRequest)
+
+	require.Equal(t, http.StatusNoContent, logoutRecorder.Result().StatusCode)
+
+	loggedOutRequest, _ := http.NewRequest("GET", "/me/sessions", nil)
+	loggedOutRequest.Header.Set("Token", tokens[0])
+	loggedOutRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(loggedOutRecorder, loggedOutRequest)
+
+	assert.Equal(t, http.StatusUnauthorized, loggedOutRecorder.Result().StatusCode)
+
+	sessionsRequest, _ := http.NewRequest("GET", "/me/sessions", nil)
+	sessionsRequest.Header.Set("Token", tokens[1])
+	sessionsRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(sessionsRecorder, sessionsRequest)
+
+	require.Equal(t, http.StatusOK, sessionsRecorder.Result().StatusCode)
+
+	var sessions []*models.Session
+	err := json.Unmarshal(sessionsRecorder.Body.Bytes(), &sessions)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, sessions)
+
+	currentSessions := 0
+	for _, session := range sessions {
+		if session.Current {
+			currentSessions++
+		}
+	}
+
+	assert.Equal(t, 1, currentSessions)
+}
+
+func (suite *RunnersControllerTestSuit) TestUpdateResult() {
+	t := suite.T()
+
+	var runnerId, raceId, resultId string
+	err := suite.dbHandler.QueryRow(`
+		SELECT
+			id
+		FROM
+			runners
+		WHERE
+			first_name = 'Sarah'`).Scan(&runnerId)
+
+	require.NoError(t, err)
+
+	err = suite.dbHandler.QueryRow(`
+		WITH event AS (
+			INSERT INTO
+				events(name, event_date, city, country)
+			VALUES
+				('Boston Marathon', CURRENT_DATE, 'Boston', 'USA')
+			RETURNING
+				id
+		)
+		INSERT INTO
+			races(event_id, name, distance_meters)
+		SELECT
+			id, 'Marathon', 42195
+		FROM
+			event
+		RETURNING
+			id`).Scan(&raceId)
+
+	require.NoError(t, err)
+
+	err = suite.dbHandler.QueryRow(`
+		INSERT INTO
+			results(runner_id, race_id, race_result, distance_meters, location, position, year)
+		VALUES
+			($1, $2, '02:25:00', 42195, 'Boston', 4, EXTRACT(YEAR FROM CURRENT_DATE))
+		RETURNING
+			id`, runnerId, raceId).Scan(&resultId)
+
+	require.NoError(t, err)
+
+	loginRequest, _ := http.NewRequest("POST", "/login", nil)
+	loginRequest.SetBasicAuth("admin", "admin")
+	loginRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(loginRecorder, loginRequest)
+
+	require.Equal(t, http.StatusOK, loginRecorder.Result().StatusCode)
+
+	body := `{"runner_id": "` + runnerId + `", "race_id": "` + raceId + `", "race_result": "2:15:30", "position": 2}`
+	request, _ := http.NewRequest("PUT", "/result/"+resultId, strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", loginRecorder.Header().Get("Token"))
+	request.Header.Set("If-Match", `"1"`)
+	suite.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	assert.Equal(t, `"2"`, recorder.Header().Get("ETag"))
+
+	var result models.Result
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+
+	require.NoError(t, err)
+	assert.Equal(t, resultId, result.ID)
+	assert.Equal(t, "02:15:30", result.RaceResult.String())
+	assert.Equal(t, 2, result.Position)
+	assert.Equal(t, "Boston", result.Location)
+	assert.Equal(t, 2, result.Version)
+
+	var seasonBest string
+	err = suite.dbHandler.QueryRow(`
+		SELECT
+			race_result
+		FROM
+			runner_bests
+		WHERE
+			runner_id = $1
+			AND
+			distance_meters = 42195
+			AND
+			season = EXTRACT(YEAR FROM CURRENT_DATE)`, runnerId).Scan(&seasonBest)
+
+	require.NoError(t, err)
+	assert.Equal(t, "02:15:30", seasonBest)
+}
+
+func (suite *RunnersControllerTestSuit) TestRunnerLifecycle() {
+	t := suite.T()
+
+	var runnerId string
+	err := suite.dbHandler.QueryRow(`
+		INSERT INTO
+			runners(first_name, last_name, age, country)
+		VALUES
+			('Paula', 'Radcliffe', 50, 'United Kingdom')
+		RETURNING
+			id`).Scan(&runnerId)
+
+	require.NoError(t, err)
+
+	loginRequest, _ := http.NewRequest("POST", "/login", nil)
+	loginRequest.SetBasicAuth("admin", "admin")
+	loginRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(loginRecorder, loginRequest)
+
+	require.Equal(t, http.StatusOK, loginRecorder.Result().StatusCode)
+
+	token := loginRecorder.Header().Get("Token")
+	serve := func(method string, url string, headers ...string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(method, url, nil)
+		recorder := httptest.NewRecorder()
+
+		request.Header.Set("Token", token)
+		for i := 0; i+1 < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		suite.router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusPreconditionRequired, serve("POST", "/runner/"+runnerId+"/deactivate").Result().StatusCode)
+
+	recorder := serve("POST", "/runner/"+runnerId+"/deactivate", "If-Match", `"1"`)
+
+	require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+
+	var runner models.Runner
+	err = json.Unmarshal(recorder.Body.Bytes(), &runner)
+
+	require.NoError(t, err)
+	assert.False(t, runner.IsActive)
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/runner/"+runnerId).Result().StatusCode)
+	assert.Equal(t, http.StatusOK, serve("GET", "/runner/"+runnerId+"?include_inactive=true").Result().StatusCode)
+
+	runnersService := services.NewRunnersService(
+		repositories.NewRunnersRepository(suite.dbHandler),
+		repositories.NewResultsRepository(suite.dbHandler),
+		repositories.NewUnitOfWork(suite.dbHandler),
+		testSeason,
+		time.Hour)
+	_, responseErr := runnersService.GetRunnersResults(suite.ctx, runnerId, &models.ResultsParams{})
+
+	require.NotNil(t, responseErr)
+	assert.Equal(t, http.StatusNotFound, responseErr.Status)
+
+	_, responseErr = runnersService.GetRunnersResults(suite.ctx, runnerId, &models.ResultsParams{IncludeInactive: "true"})
+
+	assert.Nil(t, responseErr)
+
+	var batch models.RunnersBatch
+	err = json.Unmarshal(serve("GET", "/runner?country=United%20Kingdom&include_inactive=true").Body.Bytes(), &batch)
+
+	require.NoError(t, err)
+	assert.Len(t, batch.Runners, 1)
+
+	err = json.Unmarshal(serve("GET", "/runner?country=United%20Kingdom").Body.Bytes(), &batch)
+
+	require.NoError(t, err)
+	assert.Empty(t, batch.Runners)
+
+	assert.Equal(t, http.StatusPreconditionFailed, serve("POST", "/runner/"+runnerId+"/reactivate", "If-Match", `"1"`).Result().StatusCode)
+
+	recorder = serve("POST", "/runner/"+runnerId+"/reactivate", "If-Match", `"2"`)
+
+	require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+
+	runnerTag := recorder.Header().Get("ETag")
+
+	assert.Equal(t, `"3"`, runnerTag)
+	assert.Equal(t, http.StatusOK, serve("GET", "/runner/"+runnerId).Result().StatusCode)
+	assert.Equal(t, http.StatusNotModified, serve("GET", "/runner/"+runnerId, "If-None-Match", runnerTag).Result().StatusCode)
+	assert.Equal(t, http.StatusPreconditionRequired, serve("DELETE", "/runner/"+runnerId+"?purge=true").Result().StatusCode)
+
+	require.Equal(t, http.StatusOK, serve("DELETE", "/runner/"+runnerId+"?purge=true", "If-Match", runnerTag).Result().StatusCode)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/runner/"+runnerId+"?include_inactive=true").Result().StatusCode)
+
+	_, responseErr = runnersService.GetRunnersResults(suite.ctx, runnerId, &models.ResultsParams{IncludeInactive: "true"})
+
+	require.NotNil(t, responseErr)
+	assert.Equal(t, models.ERROR_CODE_RUNNER_NOT_FOUND, responseErr.Code)
+}
+
+func (suite *RunnersControllerTestSuit) TestIdempotentCreateRunner() {
+	t := suite.T()
+
+	loginRequest, _ := http.NewRequest("POST", "/login", nil)
+	loginRequest.SetBasicAuth("admin", "admin")
+	loginRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(loginRecorder, loginRequest)
+
+	require.Equal(t, http.StatusOK, loginRecorder.Result().StatusCode)
+
+	token := loginRecorder.Header().Get("Token")
+	createRunner := func(body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/runner", strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+
+		request.Header.Set("Token", token)
+		request.Header.Set("Idempotency-Key", "8f3c1e52-create-runner")
+		suite.router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	body := `{"first_name": "Sifan", "last_name": "Hassan", "age": 31, "country": "Netherlands"}`
+	first := createRunner(body)
+
+	require.Equal(t, http.StatusOK, first.Result().StatusCode)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	retry := createRunner(body)
+
+	require.Equal(t, http.StatusOK, retry.Result().StatusCode)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, first.Header().Get("ETag"), retry.Header().Get("ETag"))
+
+	reordered := createRunner(`{"country":"Netherlands","age":31,"last_name":"Hassan","first_name":"Sifan"}`)
+
+	require.Equal(t, http.StatusOK, reordered.Result().StatusCode)
+	assert.Equal(t, "true", reordered.Header().Get("Idempotent-Replayed"))
+
+	var count int
+	err := suite.dbHandler.QueryRow("SELECT COUNT(*) FROM runners WHERE last_name = 'Hassan'").Scan(&count)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	reused := createRunner(`{"first_name": "Sifan", "last_name": "Hassan", "age": 32, "country": "Netherlands"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Result().StatusCode)
+
+	var problem models.Problem
+	err = json.Unmarshal(reused.Body.Bytes(), &problem)
 
 	require.NoError(t, err)
+	assert.Equal(t, models.ERROR_CODE_IDEMPOTENCY_KEY_REUSED, problem.Code)
+
+	_, err = suite.dbHandler.Exec("DELETE FROM runners WHERE last_name = 'Hassan'")
 
-	assert.NotEmpty(t, runners)
-	assert.Equal(t, 4, len(runners))
+	require.NoError(t, err)
 }
 
 func TestRunnersControllerTestSuite(t *testing.T) {
 	suite.Run(t, new(RunnersControllerTestSuit))
 }
 
-func TestGetRunnersErrResponseCountryAndYear(t *testing.T) {
+func TestGetRunnersErrResponseInvalidSort(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	expectActiveSession(mock, models.PERMISSION_RUNNERS_READ)
+
+	router := initTestRouter(dbHandler)
+	request, _ := http.NewRequest("GET", "/runner?country=france&year=2018&sort=first_name", nil)
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "user"))
+	request.Header.Set("X-Request-ID", "invalid-sort")
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+	assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+
+	var problem models.Problem
+	err := json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, models.ERROR_CODE_INVALID_QUERY_PARAM, problem.Code)
+	assert.Equal(t, "/runner", problem.Instance)
+	assert.Equal(t, "invalid-sort", problem.RequestID)
+	assert.Equal(t, []*models.FieldError{{Field: "sort", Message: "Invalid sort field"}}, problem.Errors)
+}
+
+func TestGetRunnersErrResponseDatabaseError(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	expectActiveSession(mock, models.PERMISSION_RUNNERS_READ)
+
+	mock.ExpectQuery("SELECT").WillReturnError(errors.New(`pq: relation "runners" does not exist`))
+
+	router := initTestRouter(dbHandler)
+	request, _ := http.NewRequest("GET", "/runner", nil)
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "user"))
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Result().StatusCode)
+	assert.NotContains(t, recorder.Body.String(), "relation")
+
+	var problem models.Problem
+	err := json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.ERROR_CODE_INTERNAL, problem.Code)
+	assert.Equal(t, responses.INTERNAL_ERROR_DETAIL, problem.Detail)
+}
+
+func TestGetRunnersErrResponseQueryTimeout(t *testing.T) {
 	dbHandler, mock, _ := sqlmock.New()
 	defer dbHandler.Close()
 
-	columnsUsers := []string{"user_role"}
+	expectActiveSession(mock, models.PERMISSION_RUNNERS_READ)
 
-	mock.ExpectQuery("SELECT user_role").WillReturnRows(
-		sqlmock.NewRows(columnsUsers).AddRow(
-			"user",
-		),
+	mock.ExpectQuery("SELECT").WillDelayFor(time.Second).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}),
 	)
 
+	router := middleware.Chain(initTestRouter(dbHandler), middleware.QueryTimeout(50*time.Millisecond))
+	request, _ := http.NewRequest("GET", "/runner", nil)
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "user"))
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusGatewayTimeout, recorder.Result().StatusCode)
+}
+
+func TestGetRunnersErrResponseInvalidToken(t *testing.T) {
+	dbHandler, _, _ := sqlmock.New()
+	defer dbHandler.Close()
+
 	router := initTestRouter(dbHandler)
-	request, _ := http.NewRequest("GET", "/runner?country=france&year=2018", nil)
+	request, _ := http.NewRequest("GET", "/runner", nil)
 	recorder := httptest.NewRecorder()
 
 	request.Header.Set("Token", "token")
 	router.ServeHTTP(recorder, request)
 
+	assert.Equal(t, http.StatusUnauthorized, recorder.Result().StatusCode)
+}
+
+func TestGetRunnersErrResponseRevokedSession(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	mock.ExpectQuery("SELECT last_seen_at").WillReturnRows(sqlmock.NewRows([]string{"last_seen_at"}))
+
+	router := initTestRouter(dbHandler)
+	request, _ := http.NewRequest("GET", "/runner", nil)
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "user"))
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Result().StatusCode)
+}
+
+func TestGetRunnersErrResponseMissingPermission(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	expectActiveSession(mock, models.PERMISSION_RUNNERS_READ)
+
+	router := initTestRouter(dbHandler)
+	request, _ := http.NewRequest("DELETE", "/runner/1", nil)
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "user"))
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Result().StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRunnersErrResponseTouchesIdleSession(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	// Only sessions idle for a while are written to
+	mock.ExpectQuery("SELECT last_seen_at").WillReturnRows(
+		sqlmock.NewRows([]string{"last_seen_at"}).AddRow(time.Now().Add(-time.Hour)),
+	)
+	mock.ExpectExec("UPDATE sessions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT permission").WillReturnRows(sqlmock.NewRows([]string{"permission"}))
+
+	router := initTestRouter(dbHandler)
+	request, _ := http.NewRequest("GET", "/runner", nil)
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "user"))
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Result().StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateResultErrResponseInvalidId(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	expectActiveSession(mock, models.PERMISSION_RESULTS_WRITE)
+
+	router := initTestRouter(dbHandler)
+	body := `{"runner_id": "e5280c8b-093d-457a-a535-2127326cd1b2", "race_id": "1f6b4a0e-2c4b-11ef-9d6a-0242ac120002", "race_result": "2:15:30", "position": 2}`
+	request, _ := http.NewRequest("PUT", "/result/1", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "admin"))
+	request.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+
+	var problem models.Problem
+	err := json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.ERROR_CODE_INVALID_RESULT_ID, problem.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRunnerErrResponsePurgeForbidden(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	expectActiveSession(mock, models.PERMISSION_RUNNERS_DELETE)
+
+	router := initTestRouter(dbHandler)
+	request, _ := http.NewRequest("DELETE", "/runner/e5280c8b-093d-457a-a535-2127326cd1b2?purge=true", nil)
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "admin"))
+	request.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Result().StatusCode)
+
+	var problem models.Problem
+	err := json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.ERROR_CODE_PERMISSION_DENIED, problem.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateRunnerErrResponseMissingIfMatch(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	expectActiveSession(mock, models.PERMISSION_RUNNERS_WRITE)
+
+	router := initTestRouter(dbHandler)
+	body := `{"id": "e5280c8b-093d-457a-a535-2127326cd1b2", "first_name": "Paula", "last_name": "Radcliffe", "age": 50, "country": "United Kingdom"}`
+	request, _ := http.NewRequest("PUT", "/runner", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "admin"))
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusPreconditionRequired, recorder.Result().StatusCode)
+
+	var problem models.Problem
+	err := json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.ERROR_CODE_PRECONDITION_REQUIRED, problem.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeactivateRunnerErrResponseMissingIfMatch(t *testing.T) {
+	for _, action := range []string{"deactivate", "reactivate"} {
+		t.Run(action, func(t *testing.T) {
+			dbHandler, mock, _ := sqlmock.New()
+			defer dbHandler.Close()
+
+			expectActiveSession(mock, models.PERMISSION_RUNNERS_WRITE, models.PERMISSION_RUNNERS_DELETE)
+
+			router := initTestRouter(dbHandler)
+			request, _ := http.NewRequest("POST", "/runner/e5280c8b-093d-457a-a535-2127326cd1b2/"+action, nil)
+			recorder := httptest.NewRecorder()
+
+			request.Header.Set("Token", testAccessToken(t, "admin"))
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusPreconditionRequired, recorder.Result().StatusCode)
+
+			var problem models.Problem
+			err := json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+			require.NoError(t, err)
+			assert.Equal(t, models.ERROR_CODE_PRECONDITION_REQUIRED, problem.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateRunnerErrResponseInvalidIdempotencyKey(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	expectActiveSession(mock, models.PERMISSION_RUNNERS_WRITE)
+
+	router := initTestRouter(dbHandler)
+	body := `{"first_name": "Sifan", "last_name": "Hassan", "age": 31, "country": "Netherlands"}`
+	request, _ := http.NewRequest("POST", "/runner", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	request.Header.Set("Token", testAccessToken(t, "admin"))
+	request.Header.Set("Idempotency-Key", strings.Repeat("k", models.MAX_IDEMPOTENCY_KEY_LENGTH+1))
+	router.ServeHTTP(recorder, request)
+
 	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+
+	var problem models.Problem
+	err := json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.ERROR_CODE_INVALID_IDEMPOTENCY_KEY, problem.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func testAccessToken(t *testing.T, role string) string {
+	accessToken, err := testTokenIssuer.IssueAccessToken(&models.User{
+		ID:       "e5280c8b-093d-457a-a535-2127326cd1b2",
+		Username: role,
+		Role:     role,
+	}, "1f6b4a0e-2c4b-11ef-9d6a-0242ac120002")
+
+	require.NoError(t, err)
+
+	return accessToken
+}
+
+func expectActiveSession(mock sqlmock.Sqlmock, permissions ...string) {
+	mock.ExpectQuery("SELECT last_seen_at").WillReturnRows(
+		sqlmock.NewRows([]string{"last_seen_at"}).AddRow(time.Now()),
+	)
+
+	rows := sqlmock.NewRows([]string{"permission"})
+	for _, permission := range permissions {
+		rows.AddRow(permission)
+	}
+
+	mock.ExpectQuery("SELECT permission").WillReturnRows(rows)
 }
@@ -1,85 +1,240 @@
 package controllers
 
 import (
+	"encoding/json"
 	"net/http"
 	"runners/interfaces"
-	"runners/metrics"
-	"strconv"
+	"runners/models"
+	"runners/responses"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-const ROLE_ADMIN = "admin"
-const ROLE_USER = "user"
-
 type UsersController struct {
-	usersService interfaces.UsersService
+	usersService   interfaces.UsersService
+	trustedProxies TrustedProxies
 }
 
-func NewUsersController(usersService interfaces.UsersService) *UsersController {
+func NewUsersController(usersService interfaces.UsersService, trustedProxies TrustedProxies) *UsersController {
 	return &UsersController{
-		usersService: usersService,
+		usersService:   usersService,
+		trustedProxies: trustedProxies,
 	}
 }
 
 func (uc UsersController) Login(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
-
 	username, password, ok := r.BasicAuth()
 	if !ok {
-		metrics.HttpResponsesCounter.WithLabelValues("400").Inc()
-		http.Error(w, "Error while reading credentials", 400)
+		responses.WriteError(w, r, &models.ResponseError{
+			Message: "Error while reading credentials",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_MISSING_CREDENTIALS,
+		})
 		return
 	}
 
-	userPassword, responseErr := uc.usersService.GetUser(username)
+	user, responseErr := uc.usersService.GetUser(r.Context(), username)
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	if userPassword == "" {
-		metrics.HttpResponsesCounter.WithLabelValues("404").Inc()
-		http.Error(w, "User not found", 404)
+	if user == nil {
+		responses.WriteError(w, r, &models.ResponseError{
+			Message: "User not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_USER_NOT_FOUND,
+		})
 		return
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(userPassword), []byte(password))
+	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 
-	if err != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("401").Inc()
-		http.Error(w, "Login failed", http.StatusUnauthorized)
+	if err != nil || user.Disabled {
+		responses.WriteError(w, r, &models.ResponseError{
+			Message: "Login failed",
+			Status:  http.StatusUnauthorized,
+			Code:    models.ERROR_CODE_INVALID_CREDENTIALS,
+		})
 		return
 	}
 
-	accessToken, responseErr := uc.usersService.GenerateAccessToken(username)
+	tokens, responseErr := uc.usersService.GenerateTokens(r.Context(), user, r.UserAgent(), uc.trustedProxies.clientIP(r))
 
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues("500").Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("200").Inc()
-	w.Header().Add("Token", accessToken)
+	w.Header().Add("Token", tokens.AccessToken)
+	w.Header().Add("Refresh-Token", tokens.RefreshToken)
 	w.WriteHeader(http.StatusOK)
 }
 
-func (uc UsersController) Logout(w http.ResponseWriter, r *http.Request) {
-	metrics.HttpRequestsCounter.Inc()
+func (uc UsersController) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	refreshToken := r.Header.Get("Refresh-Token")
 
-	accessToken := r.Header.Get("Token")
+	tokens, responseErr := uc.usersService.RefreshTokens(r.Context(), refreshToken)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.Header().Add("Token", tokens.AccessToken)
+	w.Header().Add("Refresh-Token", tokens.RefreshToken)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (uc UsersController) Logout(w http.ResponseWriter, r *http.Request) {
+	principal := models.PrincipalFromContext(r.Context())
 
-	responseErr := uc.usersService.Logout(accessToken)
+	responseErr := uc.usersService.Logout(r.Context(), principal)
 	if responseErr != nil {
-		metrics.HttpResponsesCounter.WithLabelValues(strconv.Itoa(responseErr.Status)).Inc()
-		http.Error(w, responseErr.Message, responseErr.Status)
+		responses.WriteError(w, r, responseErr)
 		return
 	}
 
-	metrics.HttpResponsesCounter.WithLabelValues("204").Inc()
 	w.Header().Del("Token")
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (uc UsersController) GetSessions(w http.ResponseWriter, r *http.Request) {
+	principal := models.PrincipalFromContext(r.Context())
+
+	sessions, responseErr := uc.usersService.GetSessions(r.Context(), principal)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, sessions)
+}
+
+func (uc UsersController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	principal := models.PrincipalFromContext(r.Context())
+
+	sessionId := r.PathValue("id")
+
+	responseErr := uc.usersService.RevokeSession(r.Context(), principal, sessionId)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (uc UsersController) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var newUser models.NewUser
+	err := json.NewDecoder(r.Body).Decode(&newUser)
+
+	if err != nil {
+		responses.WriteError(w, r, responses.BadRequestBody(err))
+		return
+	}
+
+	user, responseErr := uc.usersService.CreateUser(r.Context(), &newUser)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, user)
+}
+
+func (uc UsersController) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, responseErr := uc.usersService.GetUsers(r.Context())
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, users)
+}
+
+func (uc UsersController) GetUser(w http.ResponseWriter, r *http.Request) {
+	userId := r.PathValue("id")
+
+	user, responseErr := uc.usersService.GetUserById(r.Context(), userId)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	if user == nil {
+		responses.WriteError(w, r, &models.ResponseError{
+			Message: "User not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_USER_NOT_FOUND,
+		})
+		return
+	}
+
+	responses.WriteJSON(w, r, http.StatusOK, user)
+}
+
+func (uc UsersController) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	principal := models.PrincipalFromContext(r.Context())
+
+	var update models.UserUpdate
+	err := json.NewDecoder(r.Body).Decode(&update)
+
+	if err != nil {
+		responses.WriteError(w, r, responses.BadRequestBody(err))
+		return
+	}
+
+	userId := r.PathValue("id")
+
+	responseErr := uc.usersService.UpdateUser(r.Context(), principal, userId, &update)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (uc UsersController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	principal := models.PrincipalFromContext(r.Context())
+
+	userId := r.PathValue("id")
+
+	responseErr := uc.usersService.DeleteUser(r.Context(), principal, userId)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (uc UsersController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	principal := models.PrincipalFromContext(r.Context())
+
+	var passwordChange models.PasswordChange
+	err := json.NewDecoder(r.Body).Decode(&passwordChange)
+
+	if err != nil {
+		responses.WriteError(w, r, responses.BadRequestBody(err))
+		return
+	}
+
+	responseErr := uc.usersService.ChangePassword(r.Context(), principal, &passwordChange)
+
+	if responseErr != nil {
+		responses.WriteError(w, r, responseErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
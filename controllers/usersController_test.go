@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runners/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RunnersControllerTestSuit) TestUserAdministration() {
+	t := suite.T()
+
+	adminToken := suite.login("admin", "admin")
+
+	unknownRole, _ := json.Marshal(models.NewUser{
+		Username: "coach",
+		Password: "intervals400m",
+		Role:     "superuser",
+	})
+	unknownRoleRequest, _ := http.NewRequest("POST", "/user", bytes.NewReader(unknownRole))
+	unknownRoleRequest.Header.Set("Token", adminToken)
+	unknownRoleRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(unknownRoleRecorder, unknownRoleRequest)
+
+	assert.Equal(t, http.StatusBadRequest, unknownRoleRecorder.Result().StatusCode)
+
+	newUser, _ := json.Marshal(models.NewUser{
+		Username: "coach",
+		Password: "intervals400m",
+		Role:     models.ROLE_USER,
+	})
+	createRequest, _ := http.NewRequest("POST", "/user", bytes.NewReader(newUser))
+	createRequest.Header.Set("Token", adminToken)
+	createRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(createRecorder, createRequest)
+
+	require.Equal(t, http.StatusOK, createRecorder.Result().StatusCode)
+
+	var user models.User
+	err := json.Unmarshal(createRecorder.Body.Bytes(), &user)
+
+	require.NoError(t, err)
+	assert.False(t, user.Disabled)
+	assert.NotZero(t, user.CreatedAt)
+
+	coachToken := suite.login("coach", "intervals400m")
+
+	passwordChange, _ := json.Marshal(models.PasswordChange{
+		CurrentPassword: "intervals400m",
+		NewPassword:     "tempo10kruns",
+	})
+	passwordRequest, _ := http.NewRequest("PUT", "/me/password", bytes.NewReader(passwordChange))
+	passwordRequest.Header.Set("Token", coachToken)
+	passwordRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(passwordRecorder, passwordRequest)
+
+	require.Equal(t, http.StatusNoContent, passwordRecorder.Result().StatusCode)
+
+	coachToken = suite.login("coach", "tempo10kruns")
+
+	update, _ := json.Marshal(models.UserUpdate{
+		Role:     models.ROLE_USER,
+		Disabled: true,
+	})
+	updateRequest, _ := http.NewRequest("PUT", "/user/"+user.ID, bytes.NewReader(update))
+	updateRequest.Header.Set("Token", adminToken)
+	updateRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(updateRecorder, updateRequest)
+
+	require.Equal(t, http.StatusOK, updateRecorder.Result().StatusCode)
+
+	// Disabling a user ends all of their sessions
+	runnersRequest, _ := http.NewRequest("GET", "/runner", nil)
+	runnersRequest.Header.Set("Token", coachToken)
+	runnersRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(runnersRecorder, runnersRequest)
+
+	assert.Equal(t, http.StatusUnauthorized, runnersRecorder.Result().StatusCode)
+
+	deleteRequest, _ := http.NewRequest("DELETE", "/user/"+user.ID, nil)
+	deleteRequest.Header.Set("Token", adminToken)
+	deleteRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(deleteRecorder, deleteRequest)
+
+	assert.Equal(t, http.StatusNoContent, deleteRecorder.Result().StatusCode)
+}
+
+func (suite *RunnersControllerTestSuit) login(username string, password string) string {
+	t := suite.T()
+
+	loginRequest, _ := http.NewRequest("POST", "/login", nil)
+	loginRequest.SetBasicAuth(username, password)
+	loginRecorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(loginRecorder, loginRequest)
+
+	require.Equal(t, http.StatusOK, loginRecorder.Result().StatusCode)
+
+	return loginRecorder.Header().Get("Token")
+}
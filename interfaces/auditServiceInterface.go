@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"context"
+	"runners/models"
+)
+
+type AuditService interface {
+	GetAuditEntries(ctx context.Context, params *models.AuditParams) ([]*models.AuditEntry, *models.ResponseError)
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"runners/models"
+)
+
+type BestsService interface {
+	StartRecomputeAllBests(ctx context.Context) (*models.BestsRecomputation, *models.ResponseError)
+
+	GetBestsRecomputation(ctx context.Context, recomputationId string) (*models.BestsRecomputation, *models.ResponseError)
+}
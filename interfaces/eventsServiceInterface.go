@@ -0,0 +1,26 @@
+package interfaces
+
+import (
+	"context"
+	"runners/models"
+)
+
+type EventsService interface {
+	CreateEvent(ctx context.Context, event *models.Event) (*models.Event, *models.ResponseError)
+
+	UpdateEvent(ctx context.Context, event *models.Event) *models.ResponseError
+
+	DeleteEvent(ctx context.Context, eventId string) *models.ResponseError
+
+	GetEvent(ctx context.Context, eventId string) (*models.Event, *models.ResponseError)
+
+	GetEvents(ctx context.Context, params *models.EventsParams) ([]*models.Event, *models.ResponseError)
+
+	CreateRace(ctx context.Context, race *models.Race) (*models.Race, *models.ResponseError)
+
+	UpdateRace(ctx context.Context, race *models.Race) *models.ResponseError
+
+	DeleteRace(ctx context.Context, eventId string, raceId string) *models.ResponseError
+
+	GetRaces(ctx context.Context, eventId string) ([]*models.Race, *models.ResponseError)
+}
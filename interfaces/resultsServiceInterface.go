@@ -1,11 +1,14 @@
 package interfaces
 
-import "runners/models"
+import (
+	"context"
+	"runners/models"
+)
 
 type ResultsServiceInterface interface {
-	CreateResult(result *models.Result) (*models.Result, *models.ResponseError)
+	CreateResult(ctx context.Context, result *models.Result, idempotencyKey *models.IdempotencyKey) (*models.Result, *models.ResponseError)
 
-	UpdateResult(result *models.Result) *models.ResponseError
+	UpdateResult(ctx context.Context, result *models.Result) (*models.Result, *models.ResponseError)
 
-	DeleteResult(resultId string) *models.ResponseError
+	DeleteResult(ctx context.Context, resultId string, version int) *models.ResponseError
 }
@@ -1,17 +1,24 @@
 package interfaces
 
-import "runners/models"
+import (
+	"context"
+	"runners/models"
+)
 
 type RunnersService interface {
-	CreateRunner(runner *models.Runner) (*models.Runner, *models.ResponseError)
+	CreateRunner(ctx context.Context, runner *models.Runner, idempotencyKey *models.IdempotencyKey) (*models.Runner, *models.ResponseError)
 
-	UpdateRunner(runner *models.Runner) (int64, *models.ResponseError)
+	UpdateRunner(ctx context.Context, runner *models.Runner) (int64, *models.ResponseError)
 
-	DeleteRunner(runnerId string) (int64, *models.ResponseError)
+	DeactivateRunner(ctx context.Context, runnerId string, version int) (*models.Runner, *models.ResponseError)
 
-	GetRunner(runnerId string) (*models.Runner, *models.ResponseError)
+	ReactivateRunner(ctx context.Context, runnerId string, version int) (*models.Runner, *models.ResponseError)
 
-	GetRunnersResults(runnerId string) ([]*models.Result, *models.ResponseError)
+	DeleteRunner(ctx context.Context, principal *models.Principal, runnerId string, params *models.RunnerDeleteParams) *models.ResponseError
 
-	GetRunnersBatch(country string, year string) ([]*models.Runner, *models.ResponseError)
+	GetRunner(ctx context.Context, runnerId string, params *models.RunnerParams) (*models.Runner, *models.ResponseError)
+
+	GetRunnersResults(ctx context.Context, runnerId string, params *models.ResultsParams) ([]*models.Result, *models.ResponseError)
+
+	GetRunnersBatch(ctx context.Context, params *models.RunnersBatchParams) (*models.RunnersBatch, *models.ResponseError)
 }
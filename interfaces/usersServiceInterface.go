@@ -1,13 +1,34 @@
 package interfaces
 
-import "runners/models"
+import (
+	"context"
+	"runners/models"
+)
 
 type UsersService interface {
-	GetUser(username string) (string, *models.ResponseError)
+	GetUser(ctx context.Context, username string) (*models.User, *models.ResponseError)
 
-	Logout(accessToken string) *models.ResponseError
+	Logout(ctx context.Context, principal *models.Principal) *models.ResponseError
 
-	GenerateAccessToken(username string) (string, *models.ResponseError)
+	GenerateTokens(ctx context.Context, user *models.User, userAgent string, ipAddress string) (*models.Tokens, *models.ResponseError)
 
-	AuthorizeUser(accessToken string, expectedRoles []string) (bool, *models.ResponseError)
+	RefreshTokens(ctx context.Context, refreshToken string) (*models.Tokens, *models.ResponseError)
+
+	Authenticate(ctx context.Context, accessToken string) (*models.Principal, *models.ResponseError)
+
+	GetSessions(ctx context.Context, principal *models.Principal) ([]*models.Session, *models.ResponseError)
+
+	RevokeSession(ctx context.Context, principal *models.Principal, sessionId string) *models.ResponseError
+
+	CreateUser(ctx context.Context, newUser *models.NewUser) (*models.User, *models.ResponseError)
+
+	GetUsers(ctx context.Context) ([]*models.User, *models.ResponseError)
+
+	GetUserById(ctx context.Context, userId string) (*models.User, *models.ResponseError)
+
+	UpdateUser(ctx context.Context, principal *models.Principal, userId string, update *models.UserUpdate) *models.ResponseError
+
+	DeleteUser(ctx context.Context, principal *models.Principal, userId string) *models.ResponseError
+
+	ChangePassword(ctx context.Context, principal *models.Principal, passwordChange *models.PasswordChange) *models.ResponseError
 }
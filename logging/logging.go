@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_TEXT = "text"
+)
+
+type loggerContextKey struct{}
+
+// NewLogger creates a logger writing to out with the level and format
+// configured in the logging section.
+func NewLogger(config *viper.Viper, out io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(config.GetString("logging.level")))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+
+	options := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	switch strings.ToLower(config.GetString("logging.format")) {
+	case FORMAT_JSON:
+		return slog.New(slog.NewJSONHandler(out, options)), nil
+	case FORMAT_TEXT:
+		return slog.New(slog.NewTextHandler(out, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", config.GetString("logging.format"))
+	}
+}
+
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the request scoped logger, or the default logger
+// outside of requests.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger)
+
+	if !ok {
+		return slog.Default()
+	}
+
+	return logger
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLoggerJson(t *testing.T) {
+	config := viper.New()
+	config.Set("logging.level", "warn")
+	config.Set("logging.format", "json")
+
+	var out bytes.Buffer
+	logger, err := NewLogger(config, &out)
+
+	require.NoError(t, err)
+
+	ctx := ContextWithLogger(context.Background(), logger.With("request_id", "abc"))
+	FromContext(ctx).Info("filtered")
+	FromContext(ctx).Warn("logged")
+
+	var entry map[string]any
+	err = json.Unmarshal(out.Bytes(), &entry)
+
+	require.NoError(t, err)
+	assert.Equal(t, "logged", entry["msg"])
+	assert.Equal(t, "abc", entry["request_id"])
+}
+
+func TestNewLoggerInvalid(t *testing.T) {
+	config := viper.New()
+	config.Set("logging.level", "loud")
+	config.Set("logging.format", "json")
+
+	_, err := NewLogger(config, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "invalid log level")
+
+	config.Set("logging.level", "info")
+	config.Set("logging.format", "xml")
+
+	_, err = NewLogger(config, &bytes.Buffer{})
+
+	assert.EqualError(t, err, `invalid log format "xml"`)
+}
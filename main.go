@@ -1,30 +1,225 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"runners/config"
+	"runners/logging"
+	"runners/migrations"
+	"runners/models"
+	"runners/repositories"
 	"runners/server"
+	"runners/services"
+	"runners/tracing"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
 
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	log.Println("Starting Runners App")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "recompute-bests" {
+		recomputeBests()
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		createAdmin(os.Args[2:])
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	readiness := &server.Readiness{}
+
+	config := config.InitConfig(getConfigFileName())
+	initLogging(config)
+
+	slog.Info("Starting Runners App")
+
+	slog.Info("Initializing tracing")
+	shutdownTracing, err := tracing.InitTracing(ctx, config)
+
+	if err != nil {
+		slog.Error("Error while initializing tracing", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Initializing database")
+	dbHandler := server.InitDatabase(config)
+
+	// Background jobs outlive requests, but stop before the database is closed
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	jobs := services.NewJobs(jobsCtx)
+	bestsService := newBestsService(dbHandler, config, jobs)
+	responseErr := bestsService.FailUnfinishedRecomputations(ctx)
+
+	if responseErr != nil {
+		slog.Error("Error while cleaning up bests recomputations", "error", responseErr.Message)
+		os.Exit(1)
+	}
+
+	slog.Info("Initializing Prometheus")
+	prometheusServer := server.InitPrometheus(config)
+
+	slog.Info("Initializing HTTP server")
+	httpServer := server.InitHttpServer(config, dbHandler, readiness, jobs)
+
+	serverErrors := make(chan error, 2)
+	go func() { serverErrors <- httpServer.Start() }()
+	go func() { serverErrors <- prometheusServer.Start() }()
+
+	jobs.Go(jobsCtx, bestsService.RunSeasonResets)
+
+	idempotencyService := services.NewIdempotencyService(repositories.NewIdempotencyRepository(dbHandler), server.InitIdempotencyWindow(config))
+	jobs.Go(jobsCtx, idempotencyService.RunCleanup)
+
+	readiness.SetReady(true)
+
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received")
+	case err := <-serverErrors:
+		slog.Error("Server stopped unexpectedly", "error", err)
+	}
+
+	stop()
+	readiness.SetReady(false)
+
+	// Keep serving while load balancers take the app out of rotation
+	time.Sleep(config.GetDuration("http.shutdown_delay"))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetDuration("http.shutdown_grace_period"))
+	defer cancel()
+
+	err = httpServer.Shutdown(shutdownCtx)
+
+	if err != nil {
+		slog.Error("Error while shutting down HTTP server", "error", err)
+	}
+
+	err = prometheusServer.Shutdown(shutdownCtx)
+
+	if err != nil {
+		slog.Error("Error while shutting down Prometheus", "error", err)
+	}
+
+	cancelJobs()
+	jobs.Wait()
+
+	err = dbHandler.Close()
+
+	if err != nil {
+		slog.Error("Error while closing database", "error", err)
+	}
+
+	err = shutdownTracing(shutdownCtx)
+
+	if err != nil {
+		slog.Error("Error while flushing traces", "error", err)
+	}
+
+	slog.Info("Runners App stopped")
+}
+
+func migrate(args []string) {
+	config := config.InitConfig(getConfigFileName())
+	initLogging(config)
+
+	// Migrations are run explicitly by the command
+	config.Set("database.auto_migrate", false)
+	dbHandler := server.InitDatabase(config)
+	defer dbHandler.Close()
+
+	err := migrations.RunCommand(context.Background(), dbHandler, args, os.Stdout)
+
+	if err != nil {
+		dbHandler.Close()
+		slog.Error("Error while migrating database", "error", err)
+		os.Exit(1)
+	}
+}
+
+func recomputeBests() {
+	config := config.InitConfig(getConfigFileName())
+	initLogging(config)
+
+	dbHandler := server.InitDatabase(config)
+	defer dbHandler.Close()
+
+	bestsService := newBestsService(dbHandler, config, nil)
+	recomputation, responseErr := bestsService.RecomputeAllBests(context.Background())
+
+	if responseErr != nil {
+		dbHandler.Close()
+		slog.Error("Error while recomputing bests", "error", responseErr.Message)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Recomputed %d best(s) of %d runner(s), current season is %d\n", recomputation.Bests, recomputation.RunnersDone, recomputation.Season)
+}
+
+func newBestsService(dbHandler *sql.DB, config *viper.Viper, jobs *services.Jobs) *services.BestsService {
+	return services.NewBestsService(
+		repositories.NewBestsRepository(dbHandler),
+		repositories.NewRunnersRepository(dbHandler),
+		repositories.NewUnitOfWork(dbHandler),
+		server.InitSeason(config),
+		jobs,
+	)
+}
+
+// createAdmin creates the first admin of a new database. The password is
+// read from RUNNERS_ADMIN_PASSWORD so it does not end up in the shell history.
+func createAdmin(args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: runners create-admin <username>, with the password in RUNNERS_ADMIN_PASSWORD")
+	}
 
-	log.Println("Initializing configuration")
 	config := config.InitConfig(getConfigFileName())
+	initLogging(config)
 
-	log.Println("Initializing database")
 	dbHandler := server.InitDatabase(config)
+	defer dbHandler.Close()
 
-	log.Println("Initializing Prometheus")
-	go server.InitPrometheus()
+	usersService := services.NewUsersService(repositories.NewUsersRepository(dbHandler), repositories.NewSessionsRepository(dbHandler), repositories.NewUnitOfWork(dbHandler), nil)
+	user, responseErr := usersService.CreateUser(context.Background(), &models.NewUser{
+		Username: args[0],
+		Password: os.Getenv("RUNNERS_ADMIN_PASSWORD"),
+		Role:     models.ROLE_ADMIN,
+	})
+
+	if responseErr != nil {
+		dbHandler.Close()
+		slog.Error("Error while creating admin", "error", responseErr.Message, "fields", responseErr.Fields)
+		os.Exit(1)
+	}
 
-	log.Println("Initializing HTTP server")
-	httpServer := server.InitHttpServer(config, dbHandler)
+	fmt.Printf("Created admin %s\n", user.Username)
+}
+
+func initLogging(config *viper.Viper) {
+	logger, err := logging.NewLogger(config, os.Stdout)
+
+	if err != nil {
+		log.Fatalf("Error while initializing logging: %v", err)
+	}
 
-	httpServer.Start()
+	slog.SetDefault(logger)
 }
 
 func getConfigFileName() string {
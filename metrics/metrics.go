@@ -1,21 +1,53 @@
 package metrics
 
 import (
+	"database/sql"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
-var HttpRequestsCounter = promauto.NewCounter(
+// ROUTE_UNMATCHED labels requests that matched no route, so scanners can not
+// create arbitrarily many label values.
+const ROUTE_UNMATCHED = "unmatched"
+
+var httpLabels = []string{"method", "route", "status"}
+
+var HttpRequestsCounter = promauto.NewCounterVec(
 	prometheus.CounterOpts{
-		Name: "runners_app_http_requests",
+		Name: "runners_app_http_requests_total",
 		Help: "Total number of HTTP requests",
 	},
+	httpLabels,
 )
 
-var HttpResponsesCounter = promauto.NewCounterVec(
-	prometheus.CounterOpts{
-		Name: "runners_app_http_responses",
-		Help: "Total number of HTTP responses",
+var HttpRequestDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "runners_app_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests",
+		Buckets: prometheus.DefBuckets,
 	},
-	[]string{"status"},
+	httpLabels,
 )
+
+var HttpResponseSize = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "runners_app_http_response_size_bytes",
+		Help:    "Size of HTTP response bodies",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+	},
+	httpLabels,
+)
+
+var HttpRequestsInFlight = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "runners_app_http_requests_in_flight",
+		Help: "Number of HTTP requests currently being served",
+	},
+)
+
+// RegisterDBStats exposes the connection pool statistics of dbHandler.
+func RegisterDBStats(dbHandler *sql.DB, dbName string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(dbHandler, dbName))
+}
@@ -3,23 +3,67 @@ package middleware
 import (
 	"net/http"
 	"runners/interfaces"
+	"runners/logging"
 	"runners/models"
+	"runners/responses"
 )
 
-func AuthorizeRequest(req *http.Request, usersService interfaces.UsersService, roles []string) *models.ResponseError {
-	accessToken := req.Header.Get("Token")
-	auth, responseErr := usersService.AuthorizeUser(accessToken, roles)
+type Authorizer struct {
+	usersService interfaces.UsersService
+}
 
-	if responseErr != nil {
-		return responseErr
+func NewAuthorizer(usersService interfaces.UsersService) *Authorizer {
+	return &Authorizer{
+		usersService: usersService,
 	}
+}
+
+// Authenticate rejects requests without a valid access token and puts the
+// principal into the context of all others.
+func (a *Authorizer) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken := r.Header.Get("Token")
+		principal, responseErr := a.usersService.Authenticate(r.Context(), accessToken)
 
-	if !auth {
-		return &models.ResponseError{
-			Message: "Not authorized",
-			Status:  http.StatusUnauthorized,
+		if responseErr != nil {
+			responses.WriteError(w, r, responseErr)
+			return
 		}
+
+		setAccessLogUser(r, principal.Username)
+
+		ctx := models.ContextWithPrincipal(r.Context(), principal)
+		ctx = logging.ContextWithLogger(ctx, logging.FromContext(ctx).With("user", principal.Username))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequirePermission has to run after Authenticate.
+func RequirePermission(permission string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := models.PrincipalFromContext(r.Context())
+
+			if principal == nil || !principal.HasPermission(permission) {
+				responses.WriteError(w, r, &models.ResponseError{
+					Message: "Not authorized",
+					Status:  http.StatusForbidden,
+					Code:    models.ERROR_CODE_PERMISSION_DENIED,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Protect wraps handler with authentication and, unless permission is empty,
+// a check that the role of the principal grants permission.
+func (a *Authorizer) Protect(permission string, handler http.HandlerFunc) http.Handler {
+	if permission == "" {
+		return Chain(handler, a.Authenticate)
 	}
 
-	return nil
+	return Chain(handler, a.Authenticate, RequirePermission(permission))
 }
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runners/logging"
+	"runners/models"
+	"runners/tracing"
+	"time"
+)
+
+type accessLogContextKey struct{}
+
+// accessLog collects what inner middlewares learn about the request.
+type accessLog struct {
+	user string
+}
+
+// Logging puts a logger tagged with the request and trace ids into the
+// context and writes an access log entry for every request. It has to run
+// after RequestID and Tracing.
+func Logging(router *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.FromContext(r.Context()).With("request_id", models.RequestIDFromContext(r.Context()))
+
+			traceId := tracing.TraceID(r.Context())
+			if traceId != "" {
+				logger = logger.With("trace_id", traceId)
+			}
+
+			entry := &accessLog{}
+
+			ctx := logging.ContextWithLogger(r.Context(), logger)
+			ctx = context.WithValue(ctx, accessLogContextKey{}, entry)
+
+			start := time.Now()
+			recorder := newStatusRecorder(w)
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			level := slog.LevelInfo
+			if recorder.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			logger.LogAttrs(ctx, level, "Request served",
+				slog.String("method", r.Method),
+				slog.String("route", matchedRoute(router, r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", recorder.status),
+				slog.Int("size", recorder.size),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("user", entry.user),
+			)
+		})
+	}
+}
+
+// setAccessLogUser makes the access log entry of the request name the
+// authenticated user.
+func setAccessLogUser(r *http.Request, username string) {
+	entry, ok := r.Context().Value(accessLogContextKey{}).(*accessLog)
+
+	if ok {
+		entry.user = username
+	}
+}
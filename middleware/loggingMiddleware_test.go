@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runners/logging"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingSharesRequestId(t *testing.T) {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /runner/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Error("Database query failed")
+		http.Error(w, "failed", http.StatusInternalServerError)
+	})
+	handler := Chain(router, RequestID, Logging(router))
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, nil))
+
+	request, _ := http.NewRequest("GET", "/runner/1", nil)
+	request = request.WithContext(logging.ContextWithLogger(request.Context(), logger))
+	request.Header.Set("X-Request-ID", "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var queryLog, accessLog map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &queryLog))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &accessLog))
+
+	assert.Equal(t, "req-1", queryLog["request_id"])
+	assert.Equal(t, "req-1", accessLog["request_id"])
+	assert.Equal(t, "ERROR", accessLog["level"])
+	assert.Equal(t, "GET /runner/{id}", accessLog["route"])
+	assert.Equal(t, float64(http.StatusInternalServerError), accessLog["status"])
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"runners/metrics"
+	"strconv"
+	"time"
+)
+
+// Metrics records count, latency and response size of every request labeled
+// by method, the route pattern of router that matches it and status.
+func Metrics(router *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := matchedRoute(router, r)
+
+			metrics.HttpRequestsInFlight.Inc()
+			defer metrics.HttpRequestsInFlight.Dec()
+
+			start := time.Now()
+			recorder := newStatusRecorder(w)
+			next.ServeHTTP(recorder, r)
+
+			status := strconv.Itoa(recorder.status)
+			metrics.HttpRequestsCounter.WithLabelValues(r.Method, route, status).Inc()
+			metrics.HttpRequestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+			metrics.HttpResponseSize.WithLabelValues(r.Method, route, status).Observe(float64(recorder.size))
+		})
+	}
+}
+
+// matchedRoute returns the pattern of the route of router serving r.
+func matchedRoute(router *http.ServeMux, r *http.Request) string {
+	_, route := router.Handler(r)
+
+	if route == "" {
+		return metrics.ROUTE_UNMATCHED
+	}
+
+	return route
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runners/metrics"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsLabelsRoutePattern(t *testing.T) {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /runner/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("runner"))
+	})
+	handler := Chain(router, Metrics(router))
+
+	matched := metrics.HttpRequestsCounter.WithLabelValues("GET", "GET /runner/{id}", "200")
+	unmatched := metrics.HttpRequestsCounter.WithLabelValues("GET", metrics.ROUTE_UNMATCHED, "404")
+	matchedBefore := testutil.ToFloat64(matched)
+	unmatchedBefore := testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/runner/1", "/runner/2", "/unknown"} {
+		request, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	assert.Equal(t, matchedBefore+2, testutil.ToFloat64(matched))
+	assert.Equal(t, unmatchedBefore+1, testutil.ToFloat64(unmatched))
+	assert.Zero(t, testutil.ToFloat64(metrics.HttpRequestsInFlight))
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"runners/models"
+
+	"github.com/google/uuid"
+)
+
+const MAX_REQUEST_ID_LENGTH = 128
+
+// RequestID takes the request id from the X-Request-ID header, or generates
+// one if the client sent none, and echoes it back in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get("X-Request-ID")
+
+		if requestId == "" || len(requestId) > MAX_REQUEST_ID_LENGTH {
+			requestId = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-ID", requestId)
+		next.ServeHTTP(w, r.WithContext(models.ContextWithRequestID(r.Context(), requestId)))
+	})
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"runners/tracing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Tracing continues the trace propagated in the request headers, or starts a
+// new one, with a span named after the route of router serving the request.
+// The trace id is returned in the X-Trace-ID header.
+func Tracing(router *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		withTraceId := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceId := tracing.TraceID(r.Context())
+
+			if traceId != "" {
+				w.Header().Set("X-Trace-ID", traceId)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+
+		return otelhttp.NewHandler(withTraceId, "http",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return matchedRoute(router, r)
+			}),
+		)
+	}
+}
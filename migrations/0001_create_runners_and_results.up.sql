@@ -0,0 +1,41 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- Databases set up with dbscripts/public_schema.sql before migrations
+-- existed already have these tables and are adopted as they are
+
+-- runners
+CREATE TABLE IF NOT EXISTS runners (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  first_name text NOT NULL,
+  last_name text NOT NULL,
+  age integer,
+  is_active boolean DEFAULT TRUE,
+  country text NOT NULL,
+  personal_best interval,
+  season_best interval,
+  CONSTRAINT runners_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS runners_country
+ON runners (country);
+
+CREATE INDEX IF NOT EXISTS runners_season_best
+ON runners (season_best);
+
+-- result
+CREATE TABLE IF NOT EXISTS results (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  runner_id uuid NOT NULL,
+  race_result interval NOT NULL,
+  location text NOT NULL,
+  position integer NOT NULL,
+  year integer NOT NULL,
+  CONSTRAINT results_pk PRIMARY KEY (id),
+  CONSTRAINT fk_results_runner_id FOREIGN KEY (runner_id)
+    REFERENCES runners (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS results_year
+ON results (year);
@@ -0,0 +1,5 @@
+DROP TABLE users;
+
+DROP TABLE role_permissions;
+
+DROP TABLE roles;
@@ -0,0 +1,62 @@
+CREATE TABLE roles (
+  role text NOT NULL,
+  CONSTRAINT roles_pk PRIMARY KEY (role)
+);
+
+CREATE TABLE role_permissions (
+  role text NOT NULL,
+  permission text NOT NULL,
+  CONSTRAINT role_permissions_pk PRIMARY KEY (role, permission),
+  CONSTRAINT fk_role_permissions_role FOREIGN KEY (role)
+    REFERENCES roles (role) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+INSERT INTO roles(role)
+VALUES
+  ('admin'),
+  ('user');
+
+INSERT INTO role_permissions(role, permission)
+VALUES
+  ('admin', 'runners:read'),
+  ('admin', 'runners:write'),
+  ('admin', 'runners:delete'),
+  ('admin', 'results:write'),
+  ('admin', 'results:delete'),
+  ('admin', 'users:read'),
+  ('admin', 'users:write'),
+  ('admin', 'users:delete'),
+  ('admin', 'audit:read'),
+  ('user', 'runners:read');
+
+-- Databases set up with dbscripts/update_schema.sql already have users with
+-- an access token instead of sessions. They are adopted with their roles.
+CREATE TABLE IF NOT EXISTS users (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  username text NOT NULL UNIQUE,
+  user_password text NOT NULL,
+  user_role text NOT NULL,
+  CONSTRAINT users_pk PRIMARY KEY (id)
+);
+
+DROP INDEX IF EXISTS user_access_token;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS access_token,
+ADD COLUMN IF NOT EXISTS disabled boolean NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+
+INSERT INTO roles(role)
+SELECT DISTINCT
+  user_role
+FROM
+  users
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users
+ADD CONSTRAINT fk_users_user_role FOREIGN KEY (user_role)
+  REFERENCES roles (role) MATCH SIMPLE
+  ON UPDATE NO ACTION
+  ON DELETE NO ACTION;
@@ -0,0 +1,32 @@
+CREATE TABLE sessions (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  user_id uuid NOT NULL,
+  user_agent text NOT NULL DEFAULT '',
+  ip_address text NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  last_seen_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz,
+  CONSTRAINT sessions_pk PRIMARY KEY (id),
+  CONSTRAINT fk_sessions_user_id FOREIGN KEY (user_id)
+    REFERENCES users (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id
+ON sessions (user_id);
+
+CREATE TABLE refresh_tokens (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  session_id uuid NOT NULL,
+  token_hash text NOT NULL UNIQUE,
+  expires_at timestamptz NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  used_at timestamptz,
+  CONSTRAINT refresh_tokens_pk PRIMARY KEY (id),
+  CONSTRAINT fk_refresh_tokens_session_id FOREIGN KEY (session_id)
+    REFERENCES sessions (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+CREATE TABLE audit_log (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  actor_id uuid,
+  actor text NOT NULL,
+  action text NOT NULL,
+  entity text NOT NULL,
+  entity_id text NOT NULL,
+  before_data jsonb,
+  after_data jsonb,
+  request_id text NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT audit_log_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX audit_log_entity_id
+ON audit_log (entity_id, created_at);
+
+CREATE INDEX audit_log_created_at
+ON audit_log (created_at);
@@ -0,0 +1,9 @@
+DELETE FROM role_permissions
+WHERE permission IN ('events:read', 'events:write', 'events:delete');
+
+ALTER TABLE results
+DROP COLUMN race_id;
+
+DROP TABLE races;
+
+DROP TABLE events;
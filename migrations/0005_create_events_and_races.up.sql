@@ -0,0 +1,83 @@
+-- events
+CREATE TABLE events (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  name text NOT NULL,
+  event_date date NOT NULL,
+  city text NOT NULL,
+  -- unknown for the events synthesized from existing results
+  country text,
+  CONSTRAINT events_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX events_event_date
+ON events (event_date);
+
+-- races
+CREATE TABLE races (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  event_id uuid NOT NULL,
+  name text NOT NULL,
+  -- unknown for the races synthesized from existing results
+  distance_meters integer,
+  surface text NOT NULL DEFAULT 'road',
+  certification text NOT NULL DEFAULT 'none',
+  CONSTRAINT races_pk PRIMARY KEY (id),
+  CONSTRAINT fk_races_event_id FOREIGN KEY (event_id)
+    REFERENCES events (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE,
+  CONSTRAINT races_surface_check
+    CHECK (surface IN ('road', 'track', 'indoor', 'trail', 'cross_country')),
+  CONSTRAINT races_certification_check
+    CHECK (certification IN ('none', 'national', 'aims', 'world_athletics'))
+);
+
+CREATE INDEX races_event_id
+ON races (event_id);
+
+-- Every location and year of the existing results becomes an event on
+-- January 1st of that year with a single race
+ALTER TABLE results
+ADD COLUMN race_id uuid;
+
+INSERT INTO events(name, event_date, city)
+SELECT DISTINCT
+  location || ' ' || year, make_date(GREATEST(year, 1), 1, 1), location
+FROM
+  results;
+
+INSERT INTO races(event_id, name)
+SELECT
+  id, name
+FROM
+  events;
+
+UPDATE
+  results
+SET
+  race_id = races.id
+FROM
+  races
+  JOIN events ON events.id = races.event_id
+WHERE
+  events.city = results.location
+  AND events.event_date = make_date(GREATEST(results.year, 1), 1, 1);
+
+ALTER TABLE results
+ALTER COLUMN race_id SET NOT NULL;
+
+ALTER TABLE results
+ADD CONSTRAINT fk_results_race_id FOREIGN KEY (race_id)
+  REFERENCES races (id) MATCH SIMPLE
+  ON UPDATE NO ACTION
+  ON DELETE NO ACTION;
+
+CREATE INDEX results_race_id
+ON results (race_id);
+
+INSERT INTO role_permissions(role, permission)
+VALUES
+  ('admin', 'events:read'),
+  ('admin', 'events:write'),
+  ('admin', 'events:delete'),
+  ('user', 'events:read');
@@ -0,0 +1,38 @@
+ALTER TABLE runners
+ADD COLUMN personal_best interval,
+ADD COLUMN season_best interval;
+
+CREATE INDEX runners_season_best
+ON runners (season_best);
+
+-- Only marathon bests were kept on the runner
+UPDATE
+  runners
+SET
+  personal_best = bests.personal_best,
+  season_best = bests.season_best
+FROM (
+  SELECT
+    runner_id,
+    MIN(race_result) AS personal_best,
+    MIN(race_result) FILTER (WHERE season = EXTRACT(YEAR FROM CURRENT_DATE)) AS season_best
+  FROM
+    runner_bests
+  WHERE
+    distance_meters = 42195
+  GROUP BY
+    runner_id
+) AS bests
+WHERE
+  bests.runner_id = runners.id;
+
+DROP TABLE runner_bests;
+
+ALTER TABLE results
+DROP COLUMN distance_meters;
+
+ALTER TABLE races
+DROP CONSTRAINT races_distance_meters_check;
+
+ALTER TABLE races
+ALTER COLUMN distance_meters DROP NOT NULL;
@@ -0,0 +1,59 @@
+-- Results were only recorded for marathons before races had a distance
+UPDATE
+  races
+SET
+  distance_meters = 42195
+WHERE
+  distance_meters IS NULL;
+
+ALTER TABLE races
+ALTER COLUMN distance_meters SET NOT NULL;
+
+ALTER TABLE races
+ADD CONSTRAINT races_distance_meters_check CHECK (distance_meters > 0);
+
+-- copied from the race like location and year are copied from the event
+ALTER TABLE results
+ADD COLUMN distance_meters integer;
+
+UPDATE
+  results
+SET
+  distance_meters = races.distance_meters
+FROM
+  races
+WHERE
+  races.id = results.race_id;
+
+ALTER TABLE results
+ALTER COLUMN distance_meters SET NOT NULL;
+
+-- runner_bests
+CREATE TABLE runner_bests (
+  runner_id uuid NOT NULL,
+  distance_meters integer NOT NULL,
+  season integer NOT NULL,
+  race_result interval NOT NULL,
+  CONSTRAINT runner_bests_pk PRIMARY KEY (runner_id, distance_meters, season),
+  CONSTRAINT fk_runner_bests_runner_id FOREIGN KEY (runner_id)
+    REFERENCES runners (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE INDEX runner_bests_distance_meters_season
+ON runner_bests (distance_meters, season, race_result);
+
+INSERT INTO runner_bests(runner_id, distance_meters, season, race_result)
+SELECT
+  runner_id, distance_meters, year, MIN(race_result)
+FROM
+  results
+GROUP BY
+  runner_id, distance_meters, year;
+
+DROP INDEX runners_season_best;
+
+ALTER TABLE runners
+DROP COLUMN personal_best,
+DROP COLUMN season_best;
@@ -0,0 +1,11 @@
+-- Results without a finish can not be kept
+DELETE FROM results
+WHERE status <> 'finished';
+
+ALTER TABLE results
+ALTER COLUMN race_result SET NOT NULL,
+ALTER COLUMN position SET NOT NULL;
+
+ALTER TABLE results
+DROP COLUMN status,
+DROP COLUMN reason;
@@ -0,0 +1,12 @@
+-- Results of runners that did not finish have no time and no position
+ALTER TABLE results
+ADD COLUMN status text NOT NULL DEFAULT 'finished',
+ADD COLUMN reason text,
+ADD CONSTRAINT results_status_check
+  CHECK (status IN ('finished', 'dnf', 'dns', 'dq', 'otl')),
+ADD CONSTRAINT results_finished_check
+  CHECK (status <> 'finished' OR (race_result IS NOT NULL AND position IS NOT NULL));
+
+ALTER TABLE results
+ALTER COLUMN race_result DROP NOT NULL,
+ALTER COLUMN position DROP NOT NULL;
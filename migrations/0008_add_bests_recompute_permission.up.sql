@@ -0,0 +1,3 @@
+INSERT INTO role_permissions(role, permission)
+VALUES
+  ('admin', 'bests:recompute');
@@ -0,0 +1,5 @@
+ALTER TABLE results
+DROP COLUMN version;
+
+ALTER TABLE runners
+DROP COLUMN version;
@@ -0,0 +1,6 @@
+-- Bumped on every change, sent as ETag and checked against If-Match
+ALTER TABLE runners
+ADD COLUMN version integer NOT NULL DEFAULT 1;
+
+ALTER TABLE results
+ADD COLUMN version integer NOT NULL DEFAULT 1;
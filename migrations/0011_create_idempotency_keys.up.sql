@@ -0,0 +1,16 @@
+CREATE TABLE idempotency_keys (
+  user_id uuid NOT NULL,
+  route text NOT NULL,
+  idempotency_key text NOT NULL,
+  request_hash text NOT NULL,
+  response_body jsonb NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT idempotency_keys_pk PRIMARY KEY (user_id, route, idempotency_key),
+  CONSTRAINT fk_idempotency_keys_user_id FOREIGN KEY (user_id)
+    REFERENCES users (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idempotency_keys_created_at
+ON idempotency_keys (created_at);
@@ -0,0 +1 @@
+DROP TABLE bests_recomputations;
@@ -0,0 +1,16 @@
+-- Rebuilds of the bests of all runners run in the background and report
+-- their progress here
+CREATE TABLE bests_recomputations (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  status text NOT NULL DEFAULT 'running',
+  season integer NOT NULL,
+  runners integer NOT NULL,
+  runners_done integer NOT NULL DEFAULT 0,
+  bests bigint NOT NULL DEFAULT 0,
+  error text,
+  started_at timestamptz NOT NULL DEFAULT now(),
+  finished_at timestamptz,
+  CONSTRAINT bests_recomputations_pk PRIMARY KEY (id),
+  CONSTRAINT bests_recomputations_status_check
+    CHECK (status IN ('running', 'succeeded', 'failed'))
+);
@@ -0,0 +1,3 @@
+-- Replays answer with the status of the original response
+ALTER TABLE idempotency_keys
+ADD COLUMN response_status integer NOT NULL DEFAULT 200;
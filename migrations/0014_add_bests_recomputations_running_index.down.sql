@@ -0,0 +1 @@
+DROP INDEX bests_recomputations_running;
@@ -0,0 +1,4 @@
+-- Only one recomputation of all bests runs at a time
+CREATE UNIQUE INDEX bests_recomputations_running
+ON bests_recomputations (status)
+WHERE status = 'running';
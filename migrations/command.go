@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const MIGRATE_USAGE = "usage: runners migrate up | down [steps] | status"
+
+// RunCommand executes the migrate subcommand given by args and writes its
+// report to out.
+func RunCommand(ctx context.Context, dbHandler *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(MIGRATE_USAGE)
+	}
+
+	migrator, err := NewMigrator(dbHandler)
+
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) > 1 {
+			return errors.New(MIGRATE_USAGE)
+		}
+
+		count, err := migrator.Up(ctx)
+		fmt.Fprintf(out, "Applied %d migration(s)\n", count)
+
+		return err
+	case "down":
+		steps, err := parseSteps(args[1:])
+
+		if err != nil {
+			return err
+		}
+
+		count, err := migrator.Down(ctx, steps)
+		fmt.Fprintf(out, "Rolled back %d migration(s)\n", count)
+
+		return err
+	case "status":
+		if len(args) > 1 {
+			return errors.New(MIGRATE_USAGE)
+		}
+
+		statuses, err := migrator.Status(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(writer, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+
+		return writer.Flush()
+	default:
+		return errors.New(MIGRATE_USAGE)
+	}
+}
+
+// parseSteps defaults to rolling back a single migration.
+func parseSteps(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+
+	steps, err := strconv.Atoi(args[0])
+
+	if len(args) > 1 || err != nil || steps <= 0 {
+		return 0, errors.New(MIGRATE_USAGE)
+	}
+
+	return steps, nil
+}
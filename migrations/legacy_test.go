@@ -0,0 +1,80 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"runners/migrations"
+	"runners/testhelpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/lib/pq"
+)
+
+func TestUpAdoptsLegacySchema(t *testing.T) {
+	ctx := context.Background()
+	pgContainer, err := testhelpers.CreateLegacyPostgresContainer(ctx)
+
+	require.NoError(t, err)
+
+	defer pgContainer.Terminate(ctx)
+
+	dbHandler, err := sql.Open("postgres", pgContainer.ConnectionString)
+
+	require.NoError(t, err)
+
+	defer dbHandler.Close()
+
+	migrator, err := migrations.NewMigrator(dbHandler)
+
+	require.NoError(t, err)
+
+	_, err = migrator.Up(ctx)
+
+	require.NoError(t, err)
+
+	var runners, results, bests int
+	err = dbHandler.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM runners),
+			(SELECT COUNT(*) FROM results WHERE race_id IS NOT NULL),
+			(SELECT COUNT(*) FROM runner_bests)`).Scan(&runners, &results, &bests)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, runners)
+	assert.Equal(t, 1, results)
+	assert.Equal(t, 1, bests)
+
+	var permissions int
+	err = dbHandler.QueryRow(`
+		SELECT
+			COUNT(*)
+		FROM
+			users
+			JOIN role_permissions ON role_permissions.role = users.user_role
+		WHERE
+			users.username = 'admin'
+			AND
+			NOT users.disabled`).Scan(&permissions)
+
+	require.NoError(t, err)
+	assert.NotZero(t, permissions)
+
+	var hasAccessToken bool
+	err = dbHandler.QueryRow(`
+		SELECT
+			EXISTS (
+				SELECT
+					1
+				FROM
+					information_schema.columns
+				WHERE
+					table_name = 'users'
+					AND
+					column_name = 'access_token')`).Scan(&hasAccessToken)
+
+	require.NoError(t, err)
+	assert.False(t, hasAccessToken)
+}
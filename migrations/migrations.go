@@ -0,0 +1,371 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed *.sql
+var migrationFiles embed.FS
+
+// MIGRATIONS_LOCK_ID identifies the advisory lock held while migrating, so
+// instances starting at the same time apply the migrations one after another.
+const MIGRATIONS_LOCK_ID = 2_024_061_700
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+type Migrator struct {
+	dbHandler  *sql.DB
+	migrations []*Migration
+}
+
+func NewMigrator(dbHandler *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		dbHandler:  dbHandler,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies all pending migrations in order and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn, applied map[int64]*appliedMigration) error {
+		for _, migration := range m.migrations {
+			if applied[migration.Version] != nil {
+				continue
+			}
+
+			err := execMigration(ctx, conn, migration.Up, `
+				INSERT INTO
+					schema_migrations(version, name, checksum)
+				VALUES
+					($1, $2, $3)`, migration.Version, migration.Name, migration.Checksum)
+
+			if err != nil {
+				return fmt.Errorf("migration %s failed: %w", migration, err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Down rolls back the given number of most recently applied migrations and
+// returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn, applied map[int64]*appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+
+			if applied[migration.Version] == nil {
+				continue
+			}
+
+			err := execMigration(ctx, conn, migration.Down, `
+				DELETE FROM
+					schema_migrations
+				WHERE
+					version = $1`, migration.Version)
+
+			if err != nil {
+				return fmt.Errorf("rollback of migration %s failed: %w", migration, err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	statuses := make([]*MigrationStatus, 0, len(m.migrations))
+
+	err := m.withLock(ctx, func(conn *sql.Conn, applied map[int64]*appliedMigration) error {
+		for _, migration := range m.migrations {
+			status := &MigrationStatus{
+				Version: migration.Version,
+				Name:    migration.Name,
+			}
+
+			if applied[migration.Version] != nil {
+				status.Applied = true
+				status.AppliedAt = applied[migration.Version].appliedAt
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// LatestVersion is the version of the newest migration in this build.
+func (m *Migrator) LatestVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// CurrentVersion is the version of the newest applied migration. Unlike the
+// other methods it does not wait for the migrations lock.
+func (m *Migrator) CurrentVersion(ctx context.Context) (int64, error) {
+	var version int64
+	err := m.dbHandler.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(MAX(version), 0)
+		FROM
+			schema_migrations`).Scan(&version)
+
+	return version, err
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// withLock runs work on a single connection holding the migrations advisory
+// lock, after making sure the applied migrations match the embedded ones.
+func (m *Migrator) withLock(ctx context.Context, work func(conn *sql.Conn, applied map[int64]*appliedMigration) error) error {
+	conn, err := m.dbHandler.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", MIGRATIONS_LOCK_ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to acquire migrations lock: %w", err)
+	}
+
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", MIGRATIONS_LOCK_ID)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL,
+			name text NOT NULL,
+			checksum text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT schema_migrations_pk PRIMARY KEY (version)
+		)`)
+
+	if err != nil {
+		return err
+	}
+
+	applied, err := queryAppliedMigrations(ctx, conn)
+
+	if err != nil {
+		return err
+	}
+
+	err = m.verify(applied)
+
+	if err != nil {
+		return err
+	}
+
+	return work(conn, applied)
+}
+
+// verify fails if an applied migration is unknown to this build or was
+// changed after it had been applied.
+func (m *Migrator) verify(applied map[int64]*appliedMigration) error {
+	known := make(map[int64]*Migration, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, appliedMigration := range applied {
+		migration := known[version]
+
+		if migration == nil {
+			return fmt.Errorf("applied migration %04d_%s is unknown", version, appliedMigration.name)
+		}
+
+		if migration.Checksum != appliedMigration.checksum {
+			return fmt.Errorf("checksum of migration %s does not match the applied one", migration)
+		}
+	}
+
+	return nil
+}
+
+func queryAppliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]*appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT
+			version, name, checksum, applied_at
+		FROM
+			schema_migrations`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int64]*appliedMigration)
+
+	for rows.Next() {
+		var version int64
+		migration := &appliedMigration{}
+
+		err := rows.Scan(&version, &migration.name, &migration.checksum, &migration.appliedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = migration
+	}
+
+	return applied, rows.Err()
+}
+
+// execMigration runs the statements of a migration and the update of
+// schema_migrations in one transaction.
+func execMigration(ctx context.Context, conn *sql.Conn, statements string, bookkeeping string, args ...any) error {
+	transaction, err := conn.BeginTx(ctx, &sql.TxOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	_, err = transaction.ExecContext(ctx, statements)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = transaction.ExecContext(ctx, bookkeeping, args...)
+
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// loadMigrations reads the migrations from fsys. Every migration consists of
+// a <version>_<name>.up.sql and a <version>_<name>.down.sql file.
+func loadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+
+		if migration == nil {
+			migration = &Migration{
+				Version: version,
+				Name:    match[2],
+			}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			checksum := sha256.Sum256(content)
+			migration.Up = string(content)
+			migration.Checksum = hex.EncodeToString(checksum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
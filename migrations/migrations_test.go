@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrationsOrdered(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"README.md":            {Data: []byte("ignored")},
+	}
+
+	migrations, err := loadMigrations(fsys)
+
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE a;", migrations[0].Down)
+	assert.Equal(t, "0002_second", migrations[1].String())
+	assert.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+}
+
+func TestLoadMigrationsMissingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql": {Data: []byte("CREATE TABLE a ();")},
+	}
+
+	_, err := loadMigrations(fsys)
+
+	assert.ErrorContains(t, err, "needs both an up and a down file")
+}
+
+func TestLoadMigrationsDuplicateVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"0001_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"0001_second.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+
+	_, err := loadMigrations(fsys)
+
+	assert.ErrorContains(t, err, "migration version 1 is used by")
+}
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version)
+	}
+}
+
+func TestUpChecksumMismatch(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").WillReturnRows(
+		sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).AddRow(
+			1, "create_runners_and_results", "changed", time.Now(),
+		),
+	)
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	migrator, err := NewMigrator(dbHandler)
+
+	require.NoError(t, err)
+
+	count, err := migrator.Up(context.Background())
+
+	assert.Zero(t, count)
+	assert.ErrorContains(t, err, "checksum of migration 0001_create_runners_and_results does not match")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunCommandInvalidArguments(t *testing.T) {
+	var out bytes.Buffer
+
+	for _, args := range [][]string{{}, {"sideways"}, {"down", "0"}, {"down", "two"}, {"up", "1"}} {
+		err := RunCommand(context.Background(), nil, args, &out)
+
+		assert.EqualError(t, err, MIGRATE_USAGE, "args %v", args)
+	}
+
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AUDIT_ACTION_CREATE = "create"
+	AUDIT_ACTION_UPDATE = "update"
+	AUDIT_ACTION_DELETE = "delete"
+)
+
+const (
+	AUDIT_ENTITY_RUNNER = "runner"
+	AUDIT_ENTITY_RESULT = "result"
+	AUDIT_ENTITY_EVENT  = "event"
+	AUDIT_ENTITY_RACE   = "race"
+)
+
+// AUDIT_ACTOR_SYSTEM is recorded for mutations made without an authenticated
+// principal.
+const AUDIT_ACTOR_SYSTEM = "system"
+
+// AuditEntry records a single mutation. Before and After only hold the fields
+// that were changed by it.
+type AuditEntry struct {
+	ID        string          `json:"id"`
+	ActorID   string          `json:"actor_id,omitempty"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type AuditParams struct {
+	EntityID string
+	Actor    string
+	From     string
+	To       string
+	Limit    string
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const (
+	BESTS_RECOMPUTATION_RUNNING   = "running"
+	BESTS_RECOMPUTATION_SUCCEEDED = "succeeded"
+	BESTS_RECOMPUTATION_FAILED    = "failed"
+)
+
+// BestsRecomputation reports the progress of a rebuild of the bests of all
+// runners.
+type BestsRecomputation struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Season      int        `json:"season"`
+	Runners     int        `json:"runners"`
+	RunnersDone int        `json:"runners_done"`
+	Bests       int64      `json:"bests"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	DISTANCE_5K            = 5000
+	DISTANCE_10K           = 10000
+	DISTANCE_HALF_MARATHON = 21097
+	DISTANCE_MARATHON      = 42195
+)
+
+var standardDistances = map[string]int{
+	"5k":            DISTANCE_5K,
+	"10k":           DISTANCE_10K,
+	"half_marathon": DISTANCE_HALF_MARATHON,
+	"marathon":      DISTANCE_MARATHON,
+}
+
+// ParseDistance accepts the name of a standard distance, e.g. half_marathon,
+// or any other distance in meters.
+func ParseDistance(value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if meters, ok := standardDistances[value]; ok {
+		return meters, true
+	}
+
+	meters, err := strconv.Atoi(value)
+
+	if err != nil || meters <= 0 {
+		return 0, false
+	}
+
+	return meters, true
+}
+
+// DistanceName returns the name of a standard distance and "" for any other.
+func DistanceName(meters int) string {
+	for name, standardMeters := range standardDistances {
+		if standardMeters == meters {
+			return name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,44 @@
+package models
+
+// DATE_FORMAT is the format of calendar dates in the API.
+const DATE_FORMAT = "2006-01-02"
+
+const (
+	SURFACE_ROAD          = "road"
+	SURFACE_TRACK         = "track"
+	SURFACE_INDOOR        = "indoor"
+	SURFACE_TRAIL         = "trail"
+	SURFACE_CROSS_COUNTRY = "cross_country"
+)
+
+const (
+	CERTIFICATION_NONE            = "none"
+	CERTIFICATION_NATIONAL        = "national"
+	CERTIFICATION_AIMS            = "aims"
+	CERTIFICATION_WORLD_ATHLETICS = "world_athletics"
+)
+
+// Event is a competition at one place and date, e.g. a city marathon, with
+// one or more races.
+type Event struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Date    string  `json:"date"`
+	City    string  `json:"city"`
+	Country string  `json:"country"`
+	Races   []*Race `json:"races,omitempty"`
+}
+
+type Race struct {
+	ID             string `json:"id"`
+	EventID        string `json:"event_id"`
+	Name           string `json:"name"`
+	DistanceMeters int    `json:"distance_meters"`
+	Surface        string `json:"surface"`
+	Certification  string `json:"certification"`
+}
+
+type EventsParams struct {
+	Year    string
+	Country string
+}
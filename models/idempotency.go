@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DEFAULT_IDEMPOTENCY_WINDOW      = 24 * time.Hour
+	MAX_IDEMPOTENCY_KEY_LENGTH      = 255
+	IDEMPOTENCY_ROUTE_CREATE_RUNNER = "POST /runner"
+	IDEMPOTENCY_ROUTE_CREATE_RESULT = "POST /result"
+)
+
+// IdempotencyKey identifies a request by the Idempotency-Key header a user
+// sent to a route. Replayed is set if the request was answered before and its
+// stored response is returned again with its original Status.
+type IdempotencyKey struct {
+	UserID      string
+	Route       string
+	Key         string
+	RequestHash string
+	Replayed    bool
+	Status      int
+}
+
+// IdempotentResponse is the response stored for an idempotency key.
+type IdempotentResponse struct {
+	RequestHash string
+	Status      int
+	Body        json.RawMessage
+	CreatedAt   time.Time
+}
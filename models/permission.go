@@ -0,0 +1,18 @@
+package models
+
+const (
+	PERMISSION_RUNNERS_READ    = "runners:read"
+	PERMISSION_RUNNERS_WRITE   = "runners:write"
+	PERMISSION_RUNNERS_DELETE  = "runners:delete"
+	PERMISSION_RUNNERS_PURGE   = "runners:purge"
+	PERMISSION_RESULTS_WRITE   = "results:write"
+	PERMISSION_RESULTS_DELETE  = "results:delete"
+	PERMISSION_USERS_READ      = "users:read"
+	PERMISSION_USERS_WRITE     = "users:write"
+	PERMISSION_USERS_DELETE    = "users:delete"
+	PERMISSION_AUDIT_READ      = "audit:read"
+	PERMISSION_EVENTS_READ     = "events:read"
+	PERMISSION_EVENTS_WRITE    = "events:write"
+	PERMISSION_EVENTS_DELETE   = "events:delete"
+	PERMISSION_BESTS_RECOMPUTE = "bests:recompute"
+)
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated user a request is made on behalf of.
+type Principal struct {
+	UserID      string
+	Username    string
+	Role        string
+	SessionID   string
+	Permissions []string
+}
+
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+type principalContextKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns nil for requests that passed no authentication.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+
+	return principal
+}
@@ -0,0 +1,14 @@
+package models
+
+// Problem is the RFC 7807 body of every error response.
+type Problem struct {
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	Status    int           `json:"status"`
+	Detail    string        `json:"detail"`
+	Instance  string        `json:"instance,omitempty"`
+	Code      string        `json:"code"`
+	Errors    []*FieldError `json:"errors,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	TraceID   string        `json:"trace_id,omitempty"`
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RaceTime is the time a runner needed for a race, with microsecond precision
+// like a Postgres interval. The zero value means no time, which is stored as
+// NULL and left out of JSON with omitempty.
+type RaceTime time.Duration
+
+// maxRaceTimeHours keeps race times within the range of time.Duration
+var maxRaceTimeHours = int64(math.MaxInt64/int64(time.Hour)) - 1
+
+// H:MM:SS with any number of hour digits or M:SS, both with a fraction
+var clockPattern = regexp.MustCompile(`^(?:(\d+):([0-5]\d)|([0-5]?\d)):([0-5]\d)(?:\.(\d{1,6}))?$`)
+
+// Postgres prints intervals of a day or more with a day field, e.g.
+// "1 day 02:00:00"
+var intervalPattern = regexp.MustCompile(`^(\d+) days? (\d+:[0-5]\d:[0-5]\d(?:\.\d{1,6})?)$`)
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)(?:\.(\d{1,6}))?S)?)?$`)
+
+type RaceTimeError struct {
+	Value string
+}
+
+func (e *RaceTimeError) Error() string {
+	return fmt.Sprintf("invalid race time %q, expected H:MM:SS, MM:SS.ss or an ISO 8601 duration", e.Value)
+}
+
+// ParseRaceTime accepts H:MM:SS and MM:SS, both with up to six decimal
+// places, ISO 8601 durations like PT2H1M9S and the text format of Postgres
+// intervals.
+func ParseRaceTime(value string) (RaceTime, error) {
+	value = strings.TrimSpace(value)
+
+	if match := clockPattern.FindStringSubmatch(value); match != nil {
+		return newRaceTime(value, "", match[1], match[2]+match[3], match[4], match[5])
+	}
+
+	if match := intervalPattern.FindStringSubmatch(value); match != nil {
+		clock := clockPattern.FindStringSubmatch(match[2])
+
+		return newRaceTime(value, match[1], clock[1], clock[2], clock[4], clock[5])
+	}
+
+	if match := isoDurationPattern.FindStringSubmatch(value); match != nil && value != "P" && !strings.HasSuffix(value, "T") {
+		return newRaceTime(value, match[1], match[2], match[3], match[4], match[5])
+	}
+
+	return 0, &RaceTimeError{Value: value}
+}
+
+func newRaceTime(value string, days string, hours string, minutes string, seconds string, fraction string) (RaceTime, error) {
+	parts := []int64{0, 0, 0, 0, 0}
+
+	for i, part := range []string{days, hours, minutes, seconds, fraction + strings.Repeat("0", 6-len(fraction))} {
+		if part == "" {
+			continue
+		}
+
+		number, err := strconv.ParseInt(part, 10, 64)
+
+		if err != nil {
+			return 0, &RaceTimeError{Value: value}
+		}
+
+		parts[i] = number
+	}
+
+	if parts[0] > maxRaceTimeHours/24 || parts[0]*24+parts[1] > maxRaceTimeHours {
+		return 0, &RaceTimeError{Value: value}
+	}
+
+	duration := time.Duration(parts[0]*24+parts[1])*time.Hour +
+		time.Duration(parts[2])*time.Minute +
+		time.Duration(parts[3])*time.Second +
+		time.Duration(parts[4])*time.Microsecond
+
+	return RaceTime(duration), nil
+}
+
+func (rt RaceTime) Duration() time.Duration {
+	return time.Duration(rt)
+}
+
+// String formats rt as HH:MM:SS, with as many hour digits as needed and
+// decimal places only for fractions of a second, e.g. 02:01:09.5
+func (rt RaceTime) String() string {
+	duration := rt.Duration().Round(time.Microsecond)
+	hours := duration / time.Hour
+	minutes := (duration % time.Hour) / time.Minute
+	seconds := (duration % time.Minute) / time.Second
+	micros := (duration % time.Second) / time.Microsecond
+
+	formatted := fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+
+	if micros != 0 {
+		formatted += strings.TrimRight(fmt.Sprintf(".%06d", micros), "0")
+	}
+
+	return formatted
+}
+
+// ISO8601 formats rt as an ISO 8601 duration, e.g. PT2H1M9.5S
+func (rt RaceTime) ISO8601() string {
+	duration := rt.Duration().Round(time.Microsecond)
+
+	if duration == 0 {
+		return "PT0S"
+	}
+
+	formatted := "PT"
+
+	if hours := duration / time.Hour; hours > 0 {
+		formatted += fmt.Sprintf("%dH", hours)
+	}
+
+	if minutes := (duration % time.Hour) / time.Minute; minutes > 0 {
+		formatted += fmt.Sprintf("%dM", minutes)
+	}
+
+	if remainder := duration % time.Minute; remainder > 0 {
+		seconds := strconv.FormatFloat(remainder.Seconds(), 'f', -1, 64)
+		formatted += seconds + "S"
+	}
+
+	return formatted
+}
+
+func (rt RaceTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rt.String())
+}
+
+func (rt *RaceTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*rt = 0
+		return nil
+	}
+
+	var value string
+	err := json.Unmarshal(data, &value)
+
+	if err != nil {
+		return &RaceTimeError{Value: string(data)}
+	}
+
+	parsed, err := ParseRaceTime(value)
+
+	if err != nil {
+		return err
+	}
+
+	*rt = parsed
+
+	return nil
+}
+
+// Value stores rt as a Postgres interval, the zero value as NULL.
+func (rt RaceTime) Value() (driver.Value, error) {
+	if rt == 0 {
+		return nil, nil
+	}
+
+	return rt.String(), nil
+}
+
+func (rt *RaceTime) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*rt = 0
+		return nil
+	case []byte:
+		return rt.scanString(string(value))
+	case string:
+		return rt.scanString(value)
+	default:
+		return fmt.Errorf("can not scan %T into a race time", src)
+	}
+}
+
+func (rt *RaceTime) scanString(value string) error {
+	parsed, err := ParseRaceTime(value)
+
+	if err != nil {
+		return err
+	}
+
+	*rt = parsed
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRaceTime(t *testing.T) {
+	tests := map[string]time.Duration{
+		"02:01:09":           2*time.Hour + time.Minute + 9*time.Second,
+		"1:05:03":            time.Hour + 5*time.Minute + 3*time.Second,
+		"09:58.32":           9*time.Minute + 58*time.Second + 320*time.Millisecond,
+		"3:43.13":            3*time.Minute + 43*time.Second + 130*time.Millisecond,
+		"02:01:09.123":       2*time.Hour + time.Minute + 9*time.Second + 123*time.Millisecond,
+		"152:30:00":          152*time.Hour + 30*time.Minute,
+		"PT2H1M9S":           2*time.Hour + time.Minute + 9*time.Second,
+		"PT9M58.32S":         9*time.Minute + 58*time.Second + 320*time.Millisecond,
+		"P1DT4H":             28 * time.Hour,
+		"1 day 04:00:00":     28 * time.Hour,
+		"6 days 08:12:30.25": 152*time.Hour + 12*time.Minute + 30*time.Second + 250*time.Millisecond,
+	}
+
+	for value, expected := range tests {
+		raceTime, err := ParseRaceTime(value)
+
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, raceTime.Duration(), value)
+	}
+}
+
+func TestParseRaceTimeInvalid(t *testing.T) {
+	for _, value := range []string{"", "2", "1:5:03", "02:60:00", "02:01:9", "02:01:09.1234567", "-02:01:09", "P", "PT", "P1DT", "PT1.5H", "2h1m9s"} {
+		_, err := ParseRaceTime(value)
+
+		var raceTimeErr *RaceTimeError
+		assert.ErrorAs(t, err, &raceTimeErr, value)
+	}
+}
+
+func TestRaceTimeFormat(t *testing.T) {
+	raceTime := RaceTime(2*time.Hour + time.Minute + 9*time.Second + 500*time.Millisecond)
+
+	assert.Equal(t, "02:01:09.5", raceTime.String())
+	assert.Equal(t, "PT2H1M9.5S", raceTime.ISO8601())
+	assert.Equal(t, "152:00:00", RaceTime(152*time.Hour).String())
+	assert.Equal(t, "PT0S", RaceTime(0).ISO8601())
+}
+
+func TestRaceTimeJSON(t *testing.T) {
+	var result Result
+	err := json.Unmarshal([]byte(`{"race_result": "PT2H1M9S"}`), &result)
+
+	require.NoError(t, err)
+	assert.Equal(t, "02:01:09", result.RaceResult.String())
+
+	bestJson, err := json.Marshal(&RunnerBest{PersonalBest: result.RaceResult})
+
+	require.NoError(t, err)
+	assert.Contains(t, string(bestJson), `"personal_best":"02:01:09"`)
+	assert.NotContains(t, string(bestJson), "season_best")
+
+	err = json.Unmarshal([]byte(`{"race_result": "2:1:9"}`), &result)
+
+	var raceTimeErr *RaceTimeError
+	assert.ErrorAs(t, err, &raceTimeErr)
+}
+
+func TestRaceTimeSQL(t *testing.T) {
+	var raceTime RaceTime
+
+	require.NoError(t, raceTime.Scan([]byte("1 day 02:00:00.5")))
+	assert.Equal(t, 26*time.Hour+500*time.Millisecond, raceTime.Duration())
+
+	value, err := raceTime.Value()
+
+	require.NoError(t, err)
+	assert.Equal(t, "26:00:00.5", value)
+
+	require.NoError(t, raceTime.Scan(nil))
+	value, err = raceTime.Value()
+
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
@@ -0,0 +1,15 @@
+package models
+
+import "context"
+
+type requestIdContextKey struct{}
+
+func ContextWithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdContextKey{}, requestId)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdContextKey{}).(string)
+
+	return requestId
+}
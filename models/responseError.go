@@ -1,6 +1,59 @@
 package models
 
+// Error codes are part of the API and must not change once released.
+const (
+	ERROR_CODE_INTERNAL                 = "INTERNAL_ERROR"
+	ERROR_CODE_QUERY_TIMEOUT            = "QUERY_TIMEOUT"
+	ERROR_CODE_INVALID_REQUEST_BODY     = "INVALID_REQUEST_BODY"
+	ERROR_CODE_INVALID_QUERY_PARAM      = "INVALID_QUERY_PARAMETER"
+	ERROR_CODE_INVALID_RUNNER           = "INVALID_RUNNER"
+	ERROR_CODE_INVALID_RUNNER_ID        = "INVALID_RUNNER_ID"
+	ERROR_CODE_RUNNER_NOT_FOUND         = "RUNNER_NOT_FOUND"
+	ERROR_CODE_INVALID_RACE_RESULT      = "INVALID_RACE_RESULT"
+	ERROR_CODE_INVALID_RESULT_ID        = "INVALID_RESULT_ID"
+	ERROR_CODE_RESULT_NOT_FOUND         = "RESULT_NOT_FOUND"
+	ERROR_CODE_INVALID_EVENT            = "INVALID_EVENT"
+	ERROR_CODE_INVALID_EVENT_ID         = "INVALID_EVENT_ID"
+	ERROR_CODE_EVENT_NOT_FOUND          = "EVENT_NOT_FOUND"
+	ERROR_CODE_EVENT_HAS_RESULTS        = "EVENT_HAS_RESULTS"
+	ERROR_CODE_INVALID_RACE             = "INVALID_RACE"
+	ERROR_CODE_INVALID_RACE_ID          = "INVALID_RACE_ID"
+	ERROR_CODE_RACE_NOT_FOUND           = "RACE_NOT_FOUND"
+	ERROR_CODE_RACE_HAS_RESULTS         = "RACE_HAS_RESULTS"
+	ERROR_CODE_INVALID_USER             = "INVALID_USER"
+	ERROR_CODE_INVALID_USER_ID          = "INVALID_USER_ID"
+	ERROR_CODE_INVALID_PASSWORD         = "INVALID_PASSWORD"
+	ERROR_CODE_USER_NOT_FOUND           = "USER_NOT_FOUND"
+	ERROR_CODE_USERNAME_TAKEN           = "USERNAME_TAKEN"
+	ERROR_CODE_SELF_MODIFICATION        = "SELF_MODIFICATION_NOT_ALLOWED"
+	ERROR_CODE_INVALID_SESSION_ID       = "INVALID_SESSION_ID"
+	ERROR_CODE_SESSION_NOT_FOUND        = "SESSION_NOT_FOUND"
+	ERROR_CODE_MISSING_CREDENTIALS      = "MISSING_CREDENTIALS"
+	ERROR_CODE_INVALID_CREDENTIALS      = "INVALID_CREDENTIALS"
+	ERROR_CODE_INVALID_TOKEN            = "INVALID_TOKEN"
+	ERROR_CODE_TOKEN_EXPIRED            = "TOKEN_EXPIRED"
+	ERROR_CODE_TOKEN_REUSED             = "TOKEN_REUSED"
+	ERROR_CODE_NOT_LOGGED_IN            = "NOT_LOGGED_IN"
+	ERROR_CODE_PERMISSION_DENIED        = "PERMISSION_DENIED"
+	ERROR_CODE_PRECONDITION_FAILED      = "PRECONDITION_FAILED"
+	ERROR_CODE_PRECONDITION_REQUIRED    = "PRECONDITION_REQUIRED"
+	ERROR_CODE_INVALID_IDEMPOTENCY_KEY  = "INVALID_IDEMPOTENCY_KEY"
+	ERROR_CODE_IDEMPOTENCY_KEY_REUSED   = "IDEMPOTENCY_KEY_REUSED"
+	ERROR_CODE_INVALID_RECOMPUTATION_ID = "INVALID_RECOMPUTATION_ID"
+	ERROR_CODE_RECOMPUTATION_NOT_FOUND  = "RECOMPUTATION_NOT_FOUND"
+	ERROR_CODE_RECOMPUTATION_RUNNING    = "RECOMPUTATION_RUNNING"
+)
+
 type ResponseError struct {
+	Message string        `json:"message"`
+	Status  int           `json:"-"`
+	Code    string        `json:"code"`
+	Fields  []*FieldError `json:"fields,omitempty"`
+}
+
+// FieldError points to the field of the request that failed validation, by
+// its JSON name or the name of the query parameter.
+type FieldError struct {
+	Field   string `json:"field"`
 	Message string `json:"message"`
-	Status  int    `json:"-"`
 }
@@ -1,10 +1,36 @@
 package models
 
+const (
+	RESULT_STATUS_FINISHED = "finished"
+	// did not finish
+	RESULT_STATUS_DNF = "dnf"
+	// did not start
+	RESULT_STATUS_DNS = "dns"
+	// disqualified
+	RESULT_STATUS_DQ = "dq"
+	// finished over the time limit
+	RESULT_STATUS_OTL = "otl"
+)
+
+// Result is the result of a runner in a race. DistanceMeters is copied from
+// the race, Location and Year from its event. Only finished results have a
+// position and count for the bests of the runner. Version is bumped on every
+// change.
 type Result struct {
-	ID         string `json:"id"`
-	RunnerID   string `json:"runner_id"`
-	RaceResult string `json:"race_result"`
-	Location   string `json:"location"`
-	Position   int    `json:"position,omitempty"`
-	Year       int    `json:"year"`
+	ID             string   `json:"id"`
+	RunnerID       string   `json:"runner_id"`
+	RaceID         string   `json:"race_id"`
+	Status         string   `json:"status"`
+	Reason         string   `json:"reason,omitempty"`
+	RaceResult     RaceTime `json:"race_result,omitempty"`
+	DistanceMeters int      `json:"distance_meters"`
+	Location       string   `json:"location"`
+	Position       int      `json:"position,omitempty"`
+	Year           int      `json:"year"`
+	Version        int      `json:"version"`
+}
+
+type ResultsParams struct {
+	Status          string
+	IncludeInactive string
 }
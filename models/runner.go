@@ -1,13 +1,37 @@
 package models
 
+// ANY_VERSION stands for If-Match: * and for requests that may leave out
+// If-Match. It matches every version.
+const ANY_VERSION = 0
+
+// Runner is a runner with their bests and results. Version is bumped on
+// every change of the runner, their results or their bests.
 type Runner struct {
-	ID           string    `json:"id"`
-	FirstName    string    `json:"first_name"`
-	LastName     string    `json:"last_name"`
-	Age          int       `json:"age"`
-	IsActive     bool      `json:"is_active"`
-	Country      string    `json:"country"`
-	PersonalBest string    `json:"personal_best,omitempty"`
-	SeasonBest   string    `json:"season_best,omitempty"`
-	Results      []*Result `json:"results,omitempty"`
+	ID        string        `json:"id"`
+	FirstName string        `json:"first_name"`
+	LastName  string        `json:"last_name"`
+	Age       int           `json:"age"`
+	IsActive  bool          `json:"is_active"`
+	Country   string        `json:"country"`
+	Version   int           `json:"version"`
+	Bests     []*RunnerBest `json:"bests,omitempty"`
+	Results   []*Result     `json:"results,omitempty"`
+}
+
+// RunnerBest is the personal best and the best of the current season of a
+// runner at one distance.
+type RunnerBest struct {
+	DistanceMeters int      `json:"distance_meters"`
+	Distance       string   `json:"distance,omitempty"`
+	PersonalBest   RaceTime `json:"personal_best,omitempty"`
+	SeasonBest     RaceTime `json:"season_best,omitempty"`
+}
+
+type RunnerParams struct {
+	IncludeInactive string
+}
+
+type RunnerDeleteParams struct {
+	Purge   string
+	Version int
 }
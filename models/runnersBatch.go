@@ -0,0 +1,21 @@
+package models
+
+type RunnersBatchParams struct {
+	Country         string
+	Year            string
+	IsActive        string
+	IncludeInactive string
+	MinAge          string
+	MaxAge          string
+	Distance        string
+	SortBy          string
+	Order           string
+	Limit           string
+	Cursor          string
+}
+
+type RunnersBatch struct {
+	Runners    []*Runner `json:"runners"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	TotalCount int       `json:"-"`
+}
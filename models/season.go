@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const DEFAULT_SEASON_START = "01-01"
+
+// Season tells where one season ends and the next one starts. A season is
+// named after the year it starts in, so with the default start on January 1
+// seasons are calendar years.
+type Season struct {
+	StartMonth time.Month
+	StartDay   int
+}
+
+// ParseSeasonStart parses the start of a season given as MM-DD, e.g. 10-01.
+func ParseSeasonStart(value string) (Season, error) {
+	start, err := time.Parse("01-02", value)
+
+	if err != nil || start.Format("01-02") != value || value == "02-29" {
+		return Season{}, fmt.Errorf("invalid season start %q, expected MM-DD", value)
+	}
+
+	return Season{StartMonth: start.Month(), StartDay: start.Day()}, nil
+}
+
+// String formats the start of the season as MM-DD.
+func (s Season) String() string {
+	return fmt.Sprintf("%02d-%02d", int(s.StartMonth), s.StartDay)
+}
+
+// Of returns the season the date falls in.
+func (s Season) Of(date time.Time) int {
+	if date.Before(s.Start(date.Year(), date.Location())) {
+		return date.Year() - 1
+	}
+
+	return date.Year()
+}
+
+// Start returns the first moment of the season.
+func (s Season) Start(season int, location *time.Location) time.Time {
+	return time.Date(season, s.StartMonth, s.StartDay, 0, 0, 0, 0, location)
+}
+
+// Next returns the start of the season following the one now falls in.
+func (s Season) Next(now time.Time) time.Time {
+	return s.Start(s.Of(now)+1, now.Location())
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSeasonStart(t *testing.T) {
+	season, err := ParseSeasonStart("10-01")
+
+	require.NoError(t, err)
+	assert.Equal(t, Season{StartMonth: time.October, StartDay: 1}, season)
+	assert.Equal(t, "10-01", season.String())
+
+	for _, value := range []string{"", "1-1", "13-01", "02-30", "02-29", "10-01T00:00"} {
+		_, err := ParseSeasonStart(value)
+
+		assert.Error(t, err, value)
+	}
+}
+
+func TestSeasonOf(t *testing.T) {
+	calendar, _ := ParseSeasonStart(DEFAULT_SEASON_START)
+	autumn, _ := ParseSeasonStart("10-01")
+
+	assert.Equal(t, 2024, calendar.Of(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 2024, calendar.Of(time.Date(2024, time.December, 31, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, 2023, autumn.Of(time.Date(2024, time.September, 30, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, 2024, autumn.Of(time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestSeasonNext(t *testing.T) {
+	autumn, _ := ParseSeasonStart("10-01")
+
+	assert.Equal(t, time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC), autumn.Next(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC), autumn.Next(time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)))
+}
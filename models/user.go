@@ -1,9 +1,32 @@
 package models
 
+import "time"
+
+const ROLE_ADMIN = "admin"
+const ROLE_USER = "user"
+
 type User struct {
-	ID          string `json:"id"`
-	Username    string `json:"username"`
-	Password    string `json:"-"`
-	Role        string `json:"user_role"`
-	AccessToken string `json:"access_token"`
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Password  string    `json:"-"`
+	Role      string    `json:"user_role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewUser is the only payload a plain text password for another user is accepted in.
+type NewUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"user_role"`
+}
+
+type UserUpdate struct {
+	Role     string `json:"user_role"`
+	Disabled bool   `json:"disabled"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
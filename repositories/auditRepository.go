@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"runners/models"
+	"time"
+)
+
+type AuditRepository struct {
+	dbHandler dbExecutor
+}
+
+type AuditFilter struct {
+	EntityID string
+	Actor    string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+func NewAuditRepository(dbHandler *sql.DB) *AuditRepository {
+	return &AuditRepository{
+		dbHandler: traced(dbHandler),
+	}
+}
+
+func (ar AuditRepository) QueryCreateAuditEntry(ctx context.Context, entry *models.AuditEntry) *models.ResponseError {
+	query := `
+		INSERT INTO
+			audit_log(actor_id, actor, action, entity, entity_id, before_data, after_data, request_id)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := ar.dbHandler.ExecContext(ctx, query,
+		nullString(entry.ActorID),
+		entry.Actor,
+		entry.Action,
+		entry.Entity,
+		entry.EntityID,
+		nullJson(entry.Before),
+		nullJson(entry.After),
+		entry.RequestID)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+func (ar AuditRepository) QueryGetAuditEntries(ctx context.Context, filter *AuditFilter) ([]*models.AuditEntry, *models.ResponseError) {
+	qb := &queryBuilder{}
+
+	if filter.EntityID != "" {
+		qb.where("entity_id = ?", filter.EntityID)
+	}
+
+	if filter.Actor != "" {
+		qb.where("(actor = ? OR actor_id::text = ?)", filter.Actor, filter.Actor)
+	}
+
+	if filter.From != nil {
+		qb.where("created_at >= ?", *filter.From)
+	}
+
+	if filter.To != nil {
+		qb.where("created_at < ?", *filter.To)
+	}
+
+	query := `
+		SELECT
+			id,
+			actor_id,
+			actor,
+			action,
+			entity,
+			entity_id,
+			before_data,
+			after_data,
+			request_id,
+			created_at
+		FROM
+			audit_log
+		` + qb.whereClause() + `
+		ORDER BY
+			created_at DESC,
+			id
+		LIMIT
+			` + qb.arg(filter.Limit)
+
+	rows, err := ar.dbHandler.QueryContext(ctx, query, qb.args...)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+
+	for rows.Next() {
+		var actorId sql.NullString
+		var before, after []byte
+		entry := &models.AuditEntry{}
+
+		err := rows.Scan(
+			&entry.ID,
+			&actorId,
+			&entry.Actor,
+			&entry.Action,
+			&entry.Entity,
+			&entry.EntityID,
+			&before,
+			&after,
+			&entry.RequestID,
+			&entry.CreatedAt)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		entry.ActorID = actorId.String
+		entry.Before = before
+		entry.After = after
+		entries = append(entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return entries, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{
+		String: value,
+		Valid:  value != "",
+	}
+}
+
+func nullJson(value json.RawMessage) any {
+	if len(value) == 0 {
+		return nil
+	}
+
+	return []byte(value)
+}
@@ -0,0 +1,197 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"runners/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BESTS_RECOMPUTATION_LOCK_ID identifies the advisory lock held while the
+// bests of all runners are recomputed, so only one instance does it.
+const BESTS_RECOMPUTATION_LOCK_ID = 2_024_061_701
+
+type BestsRepository struct {
+	dbHandler dbExecutor
+	db        *sql.DB
+}
+
+func NewBestsRepository(dbHandler *sql.DB) *BestsRepository {
+	return &BestsRepository{
+		dbHandler: traced(dbHandler),
+		db:        dbHandler,
+	}
+}
+
+// QueryTryLockBestsRecomputation takes the recomputation advisory lock on a
+// connection of its own and returns the function releasing it. It returns
+// nil if another instance holds the lock, instead of waiting and repeating
+// its work afterwards.
+func (br BestsRepository) QueryTryLockBestsRecomputation(ctx context.Context) (func(), *models.ResponseError) {
+	conn, err := br.db.Conn(ctx)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	var locked bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", BESTS_RECOMPUTATION_LOCK_ID).Scan(&locked)
+
+	if err != nil || !locked {
+		conn.Close()
+
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		return nil, nil
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", BESTS_RECOMPUTATION_LOCK_ID)
+		conn.Close()
+	}, nil
+}
+
+// QueryHasSucceededBestsRecomputationSince reports if a recomputation that
+// started at or after the given time succeeded.
+func (br BestsRepository) QueryHasSucceededBestsRecomputationSince(ctx context.Context, since time.Time) (bool, *models.ResponseError) {
+	query := `
+		SELECT
+			EXISTS (
+				SELECT
+					1
+				FROM
+					bests_recomputations
+				WHERE
+					status = $1
+					AND
+					started_at >= $2
+			)`
+	row := br.dbHandler.QueryRowContext(ctx, query, models.BESTS_RECOMPUTATION_SUCCEEDED, since)
+
+	var succeeded bool
+	err := row.Scan(&succeeded)
+
+	if err != nil {
+		return false, queryError(ctx, err)
+	}
+
+	return succeeded, nil
+}
+
+func (br BestsRepository) QueryCreateBestsRecomputation(ctx context.Context, season int, runners int) (*models.BestsRecomputation, *models.ResponseError) {
+	query := `
+		INSERT INTO
+			bests_recomputations(season, runners)
+		VALUES
+			($1, $2)
+		RETURNING
+			id, status, started_at`
+	row := br.dbHandler.QueryRowContext(ctx, query, season, runners)
+
+	recomputation := &models.BestsRecomputation{
+		Season:  season,
+		Runners: runners,
+	}
+	err := row.Scan(&recomputation.ID, &recomputation.Status, &recomputation.StartedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			return nil, &models.ResponseError{
+				Message: "A recomputation of the bests is already running",
+				Status:  http.StatusConflict,
+				Code:    models.ERROR_CODE_RECOMPUTATION_RUNNING,
+			}
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return recomputation, nil
+}
+
+// QueryUpdateBestsRecomputation stores the progress of the recomputation.
+func (br BestsRepository) QueryUpdateBestsRecomputation(ctx context.Context, recomputation *models.BestsRecomputation) *models.ResponseError {
+	query := `
+		UPDATE
+			bests_recomputations
+		SET
+			status = $1,
+			runners_done = $2,
+			bests = $3,
+			error = $4,
+			finished_at = $5
+		WHERE
+			id = $6`
+	_, err := br.dbHandler.ExecContext(ctx, query, recomputation.Status, recomputation.RunnersDone, recomputation.Bests,
+		nullString(recomputation.Error), recomputation.FinishedAt, recomputation.ID)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+// QueryFailUnfinishedBestsRecomputations marks the recomputations that are
+// still running as failed and returns how many there were.
+func (br BestsRepository) QueryFailUnfinishedBestsRecomputations(ctx context.Context, message string) (int64, *models.ResponseError) {
+	query := `
+		UPDATE
+			bests_recomputations
+		SET
+			status = $1,
+			error = $2,
+			finished_at = now()
+		WHERE
+			status = $3`
+	res, err := br.dbHandler.ExecContext(ctx, query, models.BESTS_RECOMPUTATION_FAILED, message, models.BESTS_RECOMPUTATION_RUNNING)
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return rowsAffected, nil
+}
+
+func (br BestsRepository) QueryGetBestsRecomputation(ctx context.Context, id string) (*models.BestsRecomputation, *models.ResponseError) {
+	query := `
+		SELECT
+			id, status, season, runners, runners_done, bests, error, started_at, finished_at
+		FROM
+			bests_recomputations
+		WHERE
+			id = $1`
+	row := br.dbHandler.QueryRowContext(ctx, query, id)
+
+	recomputation := &models.BestsRecomputation{}
+	var recomputationError sql.NullString
+	var finishedAt sql.NullTime
+	err := row.Scan(&recomputation.ID, &recomputation.Status, &recomputation.Season, &recomputation.Runners,
+		&recomputation.RunnersDone, &recomputation.Bests, &recomputationError, &recomputation.StartedAt, &finishedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	recomputation.Error = recomputationError.String
+
+	if finishedAt.Valid {
+		recomputation.FinishedAt = &finishedAt.Time
+	}
+
+	return recomputation, nil
+}
@@ -0,0 +1,472 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"runners/models"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type EventsRepository struct {
+	dbHandler dbExecutor
+}
+
+type EventsFilter struct {
+	Year    int
+	Country string
+}
+
+func NewEventsRepository(dbHandler *sql.DB) *EventsRepository {
+	return &EventsRepository{
+		dbHandler: traced(dbHandler),
+	}
+}
+
+func (er EventsRepository) QueryCreateEvent(ctx context.Context, event *models.Event) (*models.Event, *models.ResponseError) {
+	query := `
+		INSERT INTO
+			events(name, event_date, city, country)
+		VALUES
+			($1, $2, $3, $4)
+		RETURNING
+			id`
+	row := er.dbHandler.QueryRowContext(ctx, query, event.Name, event.Date, event.City, nullString(event.Country))
+
+	var eventId string
+	err := row.Scan(&eventId)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return &models.Event{
+		ID:      eventId,
+		Name:    event.Name,
+		Date:    event.Date,
+		City:    event.City,
+		Country: event.Country,
+	}, nil
+}
+
+func (er EventsRepository) QueryUpdateEvent(ctx context.Context, event *models.Event) *models.ResponseError {
+	query := `
+		UPDATE
+			events
+		SET
+			name = $1,
+			event_date = $2,
+			city = $3,
+			country = $4
+		WHERE
+			id = $5`
+	_, err := er.dbHandler.ExecContext(ctx, query, event.Name, event.Date, event.City, nullString(event.Country), event.ID)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+// QueryUpdateEventResults copies the city and year of the event to the
+// results of all of its races.
+func (er EventsRepository) QueryUpdateEventResults(ctx context.Context, event *models.Event, year int) *models.ResponseError {
+	query := `
+		UPDATE
+			results
+		SET
+			location = $1,
+			year = $2,
+			version = version + 1
+		WHERE
+			race_id IN (SELECT id FROM races WHERE event_id = $3)`
+	_, err := er.dbHandler.ExecContext(ctx, query, event.City, year, event.ID)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+// QueryGetEventRunnerIDs returns the runners with results in any race of the
+// event, sorted so that they are always locked in the same order.
+func (er EventsRepository) QueryGetEventRunnerIDs(ctx context.Context, eventId string) ([]string, *models.ResponseError) {
+	query := `
+		SELECT DISTINCT
+			results.runner_id
+		FROM
+			results
+			JOIN races ON races.id = results.race_id
+		WHERE
+			races.event_id = $1
+		ORDER BY
+			results.runner_id`
+	rows, err := er.dbHandler.QueryContext(ctx, query, eventId)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	runnerIds := make([]string, 0)
+
+	for rows.Next() {
+		var runnerId string
+
+		err := rows.Scan(&runnerId)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		runnerIds = append(runnerIds, runnerId)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return runnerIds, nil
+}
+
+func (er EventsRepository) QueryDeleteEvent(ctx context.Context, eventId string) *models.ResponseError {
+	query := `
+		DELETE FROM
+			events
+		WHERE
+			id = $1`
+	_, err := er.dbHandler.ExecContext(ctx, query, eventId)
+
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return &models.ResponseError{
+				Message: "Event has results",
+				Status:  http.StatusConflict,
+				Code:    models.ERROR_CODE_EVENT_HAS_RESULTS,
+			}
+		}
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+func (er EventsRepository) QueryGetEvent(ctx context.Context, eventId string) (*models.Event, *models.ResponseError) {
+	query := `
+		SELECT
+			id, name, event_date, city, country
+		FROM
+			events
+		WHERE
+			id = $1`
+
+	return er.queryGetEvent(ctx, query, eventId)
+}
+
+// QueryGetEventForUpdate locks the event row until the surrounding
+// transaction ends.
+func (er EventsRepository) QueryGetEventForUpdate(ctx context.Context, eventId string) (*models.Event, *models.ResponseError) {
+	query := `
+		SELECT
+			id, name, event_date, city, country
+		FROM
+			events
+		WHERE
+			id = $1
+		FOR UPDATE`
+
+	return er.queryGetEvent(ctx, query, eventId)
+}
+
+func (er EventsRepository) queryGetEvent(ctx context.Context, query string, id string) (*models.Event, *models.ResponseError) {
+	row := er.dbHandler.QueryRowContext(ctx, query, id)
+
+	var eventId, name, city string
+	var country sql.NullString
+	var eventDate time.Time
+	err := row.Scan(&eventId, &name, &eventDate, &city, &country)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return &models.Event{
+		ID:      eventId,
+		Name:    name,
+		Date:    eventDate.Format(models.DATE_FORMAT),
+		City:    city,
+		Country: country.String,
+	}, nil
+}
+
+func (er EventsRepository) QueryGetEvents(ctx context.Context, filter *EventsFilter) ([]*models.Event, *models.ResponseError) {
+	qb := &queryBuilder{}
+
+	if filter.Year != 0 {
+		qb.where("EXTRACT(YEAR FROM event_date) = ?", filter.Year)
+	}
+
+	if filter.Country != "" {
+		qb.where("country = ?", filter.Country)
+	}
+
+	query := `
+		SELECT
+			id, name, event_date, city, country
+		FROM
+			events
+		` + qb.whereClause() + `
+		ORDER BY
+			event_date DESC,
+			id`
+
+	rows, err := er.dbHandler.QueryContext(ctx, query, qb.args...)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	events := make([]*models.Event, 0)
+	var id, name, city string
+	var country sql.NullString
+	var eventDate time.Time
+
+	for rows.Next() {
+		err := rows.Scan(&id, &name, &eventDate, &city, &country)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		events = append(events, &models.Event{
+			ID:      id,
+			Name:    name,
+			Date:    eventDate.Format(models.DATE_FORMAT),
+			City:    city,
+			Country: country.String,
+		})
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return events, nil
+}
+
+func (er EventsRepository) QueryCreateRace(ctx context.Context, race *models.Race) (*models.Race, *models.ResponseError) {
+	query := `
+		INSERT INTO
+			races(event_id, name, distance_meters, surface, certification)
+		VALUES
+			($1, $2, $3, $4, $5)
+		RETURNING
+			id`
+	row := er.dbHandler.QueryRowContext(ctx, query, race.EventID, race.Name, race.DistanceMeters, race.Surface, race.Certification)
+
+	var raceId string
+	err := row.Scan(&raceId)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return &models.Race{
+		ID:             raceId,
+		EventID:        race.EventID,
+		Name:           race.Name,
+		DistanceMeters: race.DistanceMeters,
+		Surface:        race.Surface,
+		Certification:  race.Certification,
+	}, nil
+}
+
+func (er EventsRepository) QueryUpdateRace(ctx context.Context, race *models.Race) *models.ResponseError {
+	query := `
+		UPDATE
+			races
+		SET
+			name = $1,
+			distance_meters = $2,
+			surface = $3,
+			certification = $4
+		WHERE
+			id = $5`
+	_, err := er.dbHandler.ExecContext(ctx, query, race.Name, race.DistanceMeters, race.Surface, race.Certification, race.ID)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+func (er EventsRepository) QueryDeleteRace(ctx context.Context, raceId string) *models.ResponseError {
+	query := `
+		DELETE FROM
+			races
+		WHERE
+			id = $1`
+	_, err := er.dbHandler.ExecContext(ctx, query, raceId)
+
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return &models.ResponseError{
+				Message: "Race has results",
+				Status:  http.StatusConflict,
+				Code:    models.ERROR_CODE_RACE_HAS_RESULTS,
+			}
+		}
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+// QueryUpdateRaceResults copies the distance of the race to its results and
+// returns the runners of these results, sorted so that they are always locked
+// in the same order.
+func (er EventsRepository) QueryUpdateRaceResults(ctx context.Context, race *models.Race) ([]string, *models.ResponseError) {
+	query := `
+		UPDATE
+			results
+		SET
+			distance_meters = $1,
+			version = version + 1
+		WHERE
+			race_id = $2
+		RETURNING
+			runner_id`
+	rows, err := er.dbHandler.QueryContext(ctx, query, race.DistanceMeters, race.ID)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	runnerIds := make([]string, 0)
+
+	for rows.Next() {
+		var runnerId string
+
+		err := rows.Scan(&runnerId)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		if !slices.Contains(runnerIds, runnerId) {
+			runnerIds = append(runnerIds, runnerId)
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	slices.Sort(runnerIds)
+
+	return runnerIds, nil
+}
+
+func (er EventsRepository) QueryGetRace(ctx context.Context, raceId string) (*models.Race, *models.ResponseError) {
+	query := `
+		SELECT
+			id, event_id, name, distance_meters, surface, certification
+		FROM
+			races
+		WHERE
+			id = $1`
+
+	return er.queryGetRace(ctx, query, raceId)
+}
+
+// QueryGetRaceForUpdate locks the race row until the surrounding transaction
+// ends.
+func (er EventsRepository) QueryGetRaceForUpdate(ctx context.Context, raceId string) (*models.Race, *models.ResponseError) {
+	query := `
+		SELECT
+			id, event_id, name, distance_meters, surface, certification
+		FROM
+			races
+		WHERE
+			id = $1
+		FOR UPDATE`
+
+	return er.queryGetRace(ctx, query, raceId)
+}
+
+func (er EventsRepository) queryGetRace(ctx context.Context, query string, raceId string) (*models.Race, *models.ResponseError) {
+	row := er.dbHandler.QueryRowContext(ctx, query, raceId)
+
+	race := &models.Race{}
+	err := row.Scan(&race.ID, &race.EventID, &race.Name, &race.DistanceMeters, &race.Surface, &race.Certification)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return race, nil
+}
+
+func (er EventsRepository) QueryGetRaces(ctx context.Context, eventId string) ([]*models.Race, *models.ResponseError) {
+	query := `
+		SELECT
+			id, event_id, name, distance_meters, surface, certification
+		FROM
+			races
+		WHERE
+			event_id = $1
+		ORDER BY
+			distance_meters DESC,
+			name`
+	rows, err := er.dbHandler.QueryContext(ctx, query, eventId)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	races := make([]*models.Race, 0)
+
+	for rows.Next() {
+		race := &models.Race{}
+
+		err := rows.Scan(&race.ID, &race.EventID, &race.Name, &race.DistanceMeters, &race.Surface, &race.Certification)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		races = append(races, race)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return races, nil
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation"
+}
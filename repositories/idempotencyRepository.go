@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"runners/models"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	dbHandler dbExecutor
+}
+
+func NewIdempotencyRepository(dbHandler *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		dbHandler: traced(dbHandler),
+	}
+}
+
+// QueryLockIdempotencyKey makes concurrent requests with the same key wait
+// for each other until the surrounding transaction ends.
+func (ir IdempotencyRepository) QueryLockIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) *models.ResponseError {
+	query := `
+		SELECT
+			pg_advisory_xact_lock(hashtextextended($1 || ' ' || $2 || ' ' || $3, 0))`
+	_, err := ir.dbHandler.ExecContext(ctx, query, key.UserID, key.Route, key.Key)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+// QueryGetIdempotentResponse returns the response stored for the key since
+// the given time, nil if there is none.
+func (ir IdempotencyRepository) QueryGetIdempotentResponse(ctx context.Context, key *models.IdempotencyKey, since time.Time) (*models.IdempotentResponse, *models.ResponseError) {
+	query := `
+		SELECT
+			request_hash, response_status, response_body, created_at
+		FROM
+			idempotency_keys
+		WHERE
+			user_id = $1
+			AND
+			route = $2
+			AND
+			idempotency_key = $3
+			AND
+			created_at > $4`
+	row := ir.dbHandler.QueryRowContext(ctx, query, key.UserID, key.Route, key.Key, since)
+
+	response := &models.IdempotentResponse{}
+	err := row.Scan(&response.RequestHash, &response.Status, &response.Body, &response.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return response, nil
+}
+
+// QuerySaveIdempotentResponse stores the response for the key. An expired
+// response stored for the same key before is replaced.
+func (ir IdempotencyRepository) QuerySaveIdempotentResponse(ctx context.Context, key *models.IdempotencyKey, status int, body []byte) *models.ResponseError {
+	query := `
+		INSERT INTO
+			idempotency_keys(user_id, route, idempotency_key, request_hash, response_status, response_body)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, route, idempotency_key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			response_status = EXCLUDED.response_status,
+			response_body = EXCLUDED.response_body,
+			created_at = now()`
+	_, err := ir.dbHandler.ExecContext(ctx, query, key.UserID, key.Route, key.Key, key.RequestHash, status, body)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+// QueryDeleteExpiredIdempotencyKeys deletes the keys stored before the given
+// time and returns how many there were.
+func (ir IdempotencyRepository) QueryDeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, *models.ResponseError) {
+	query := `
+		DELETE FROM
+			idempotency_keys
+		WHERE
+			created_at <= $1`
+	res, err := ir.dbHandler.ExecContext(ctx, query, before)
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"strconv"
+	"strings"
+)
+
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// where adds a condition that is joined with the previous ones using AND.
+// Every "?" in the condition is replaced by a positional parameter bound to
+// the next value in args.
+func (qb *queryBuilder) where(condition string, args ...any) *queryBuilder {
+	for _, arg := range args {
+		condition = strings.Replace(condition, "?", qb.arg(arg), 1)
+	}
+
+	qb.conditions = append(qb.conditions, condition)
+
+	return qb
+}
+
+// arg binds value as the next positional parameter and returns its placeholder.
+func (qb *queryBuilder) arg(value any) string {
+	qb.args = append(qb.args, value)
+
+	return "$" + strconv.Itoa(len(qb.args))
+}
+
+func (qb *queryBuilder) whereClause() string {
+	if len(qb.conditions) == 0 {
+		return ""
+	}
+
+	return "WHERE\n\t\t\t" + strings.Join(qb.conditions, "\n\t\t\tAND\n\t\t\t")
+}
+
+func (qb *queryBuilder) clone() *queryBuilder {
+	return &queryBuilder{
+		conditions: append([]string{}, qb.conditions...),
+		args:       append([]any{}, qb.args...),
+	}
+}
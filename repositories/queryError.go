@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runners/logging"
+	"runners/models"
+)
+
+// queryError logs err with the logger of the request and maps it to a
+// response error.
+func queryError(ctx context.Context, err error) *models.ResponseError {
+	if deadlineExceeded(ctx, err) {
+		logging.FromContext(ctx).Warn("Database query timed out", "error", err)
+
+		return &models.ResponseError{
+			Message: "Database query timed out",
+			Status:  http.StatusGatewayTimeout,
+			Code:    models.ERROR_CODE_QUERY_TIMEOUT,
+		}
+	}
+
+	logging.FromContext(ctx).Error("Database query failed", "error", err)
+
+	return &models.ResponseError{
+		Message: "Database query failed",
+		Status:  http.StatusInternalServerError,
+		Code:    models.ERROR_CODE_INTERNAL,
+	}
+}
+
+// deadlineExceeded also checks the context itself, because the driver reports
+// a cancelled statement with its own error instead of context.DeadlineExceeded.
+func deadlineExceeded(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
@@ -1,242 +1,334 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"runners/models"
+
+	"github.com/lib/pq"
 )
 
 type ResultsRepository struct {
-	dbHandler   *sql.DB
-	transaction *sql.Tx
+	dbHandler dbExecutor
 }
 
 func NewResultsRepository(dbHandler *sql.DB) *ResultsRepository {
 	return &ResultsRepository{
-		dbHandler: dbHandler,
+		dbHandler: traced(dbHandler),
 	}
 }
 
-func (rr *ResultsRepository) SetTransaction(transaction *sql.Tx) {
-	rr.transaction = transaction
-}
-
-func (rr *ResultsRepository) GetTransaction() *sql.Tx {
-	return rr.transaction
-}
-
-func (rr *ResultsRepository) ClearTransaction() {
-	rr.transaction = nil
-}
-
-func (rr ResultsRepository) QueryCreateResult(result *models.Result) (*models.Result, *models.ResponseError) {
+func (rr ResultsRepository) QueryCreateResult(ctx context.Context, result *models.Result) (*models.Result, *models.ResponseError) {
 	query := `
 		INSERT INTO
-			results(runner_id, race_result, location, position, year)
+			results(runner_id, race_id, status, reason, race_result, distance_meters, location, position, year)
 		VALUES
-			($1, $2, $3, $4, $5)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING
-			id`
-	row := rr.dbHandler.QueryRow(query, result.RunnerID, result.RaceResult, result.Location, result.Position, result.Year)
+			id, version`
+	row := rr.dbHandler.QueryRowContext(ctx, query, result.RunnerID, result.RaceID, result.Status, nullString(result.Reason),
+		result.RaceResult, result.DistanceMeters, result.Location, nullInt(result.Position), result.Year)
 
 	var resultId string
-	err := row.Scan(&resultId)
+	var version int
+	err := row.Scan(&resultId, &version)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	return &models.Result{
-		ID:         resultId,
-		RunnerID:   result.RunnerID,
-		RaceResult: result.RaceResult,
-		Location:   result.Location,
-		Position:   result.Position,
-		Year:       result.Year,
+		ID:             resultId,
+		RunnerID:       result.RunnerID,
+		RaceID:         result.RaceID,
+		Status:         result.Status,
+		Reason:         result.Reason,
+		RaceResult:     result.RaceResult,
+		DistanceMeters: result.DistanceMeters,
+		Location:       result.Location,
+		Position:       result.Position,
+		Year:           result.Year,
+		Version:        version,
 	}, nil
 }
 
-func (rr ResultsRepository) QueryUpdateResult(result *models.Result) *models.ResponseError {
+func (rr ResultsRepository) QueryUpdateResult(ctx context.Context, result *models.Result) *models.ResponseError {
 	query := `
 		UPDATE
 			results
 		SET
-			race_result = $1,
-			location = $2,
-			position = $3,
-			year = $4
+			runner_id = $1,
+			race_id = $2,
+			status = $3,
+			reason = $4,
+			race_result = $5,
+			distance_meters = $6,
+			location = $7,
+			position = $8,
+			year = $9,
+			version = version + 1
 		WHERE
-			result_id = $5
-	`
-	res, err := rr.dbHandler.Exec(query, result.RaceResult, result.Location, result.Position, result.Year, result.ID)
+			id = $10`
+	res, err := rr.dbHandler.ExecContext(ctx, query, result.RunnerID, result.RaceID, result.Status, nullString(result.Reason),
+		result.RaceResult, result.DistanceMeters, result.Location, nullInt(result.Position), result.Year, result.ID)
 
 	if err != nil {
-		return &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return queryError(ctx, err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
 
 	if err != nil {
-		return &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return queryError(ctx, err)
 	}
 
 	if rowsAffected == 0 {
 		return &models.ResponseError{
 			Message: "Result not found",
 			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_RESULT_NOT_FOUND,
 		}
 	}
 
 	return nil
 }
 
-func (rr ResultsRepository) QueryDeleteResult(resultId string) (*models.Result, *models.ResponseError) {
+func (rr ResultsRepository) QueryDeleteResult(ctx context.Context, resultId string) (*models.Result, *models.ResponseError) {
 	query := `
 		DELETE FROM
 			results
 		WHERE
 			id = $1
 		RETURNING
-			runner_id, race_result, year`
-	row := rr.dbHandler.QueryRow(query, resultId)
+			runner_id, race_id, status, reason, race_result, distance_meters, location, position, year, version`
+	row := rr.dbHandler.QueryRowContext(ctx, query, resultId)
 
-	var runnerId, raceResult string
-	var year int
-	err := row.Scan(&runnerId, &raceResult, &year)
+	var runnerId, raceId, status, location string
+	var reason sql.NullString
+	var raceResult models.RaceTime
+	var position sql.NullInt64
+	var distanceMeters, year, version int
+	err := row.Scan(&runnerId, &raceId, &status, &reason, &raceResult, &distanceMeters, &location, &position, &year, &version)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, &models.ResponseError{
 				Message: "Race result not found",
 				Status:  http.StatusNotFound,
+				Code:    models.ERROR_CODE_RESULT_NOT_FOUND,
 			}
 		}
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	return &models.Result{
-		ID:         resultId,
-		RunnerID:   runnerId,
-		RaceResult: raceResult,
-		Year:       year,
+		ID:             resultId,
+		RunnerID:       runnerId,
+		RaceID:         raceId,
+		Status:         status,
+		Reason:         reason.String,
+		RaceResult:     raceResult,
+		DistanceMeters: distanceMeters,
+		Location:       location,
+		Position:       int(position.Int64),
+		Year:           year,
+		Version:        version,
 	}, nil
 }
 
-func (rr ResultsRepository) QueryGetAllRunnersResults(runnerId string) ([]*models.Result, *models.ResponseError) {
+// QueryGetResultForUpdate locks the result row until the surrounding
+// transaction ends.
+func (rr ResultsRepository) QueryGetResultForUpdate(ctx context.Context, resultId string) (*models.Result, *models.ResponseError) {
 	query := `
 		SELECT
-			id, race_result, location, position, year
+			runner_id, race_id, status, reason, race_result, distance_meters, location, position, year, version
 		FROM
 			results
-		WHERE 
-			runner_id = $1`
-	rows, err := rr.dbHandler.Query(query, runnerId)
+		WHERE
+			id = $1
+		FOR UPDATE`
+	row := rr.dbHandler.QueryRowContext(ctx, query, resultId)
+
+	var runnerId, raceId, status, location string
+	var reason sql.NullString
+	var raceResult models.RaceTime
+	var position sql.NullInt64
+	var distanceMeters, year, version int
+	err := row.Scan(&runnerId, &raceId, &status, &reason, &raceResult, &distanceMeters, &location, &position, &year, &version)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
+		if err == sql.ErrNoRows {
+			return nil, &models.ResponseError{
+				Message: "Race result not found",
+				Status:  http.StatusNotFound,
+				Code:    models.ERROR_CODE_RESULT_NOT_FOUND,
+			}
 		}
+		return nil, queryError(ctx, err)
+	}
+
+	return &models.Result{
+		ID:             resultId,
+		RunnerID:       runnerId,
+		RaceID:         raceId,
+		Status:         status,
+		Reason:         reason.String,
+		RaceResult:     raceResult,
+		DistanceMeters: distanceMeters,
+		Location:       location,
+		Position:       int(position.Int64),
+		Year:           year,
+		Version:        version,
+	}, nil
+}
+
+// QueryGetAllRunnersResults returns the results of the runner, limited to
+// the given statuses if there are any.
+func (rr ResultsRepository) QueryGetAllRunnersResults(ctx context.Context, runnerId string, statuses []string) ([]*models.Result, *models.ResponseError) {
+	qb := &queryBuilder{}
+	qb.where("runner_id = ?", runnerId)
+
+	if len(statuses) > 0 {
+		qb.where("status = ANY(?)", pq.Array(statuses))
 	}
 
+	query := `
+		SELECT
+			id, race_id, status, reason, race_result, distance_meters, location, position, year, version
+		FROM
+			results
+		` + qb.whereClause()
+	rows, err := rr.dbHandler.QueryContext(ctx, query, qb.args...)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return scanResults(ctx, rows, runnerId)
+}
+
+// QueryDeleteRunnersResults deletes all results of the runner and returns
+// them.
+func (rr ResultsRepository) QueryDeleteRunnersResults(ctx context.Context, runnerId string) ([]*models.Result, *models.ResponseError) {
+	query := `
+		DELETE FROM
+			results
+		WHERE
+			runner_id = $1
+		RETURNING
+			id, race_id, status, reason, race_result, distance_meters, location, position, year, version`
+	rows, err := rr.dbHandler.QueryContext(ctx, query, runnerId)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return scanResults(ctx, rows, runnerId)
+}
+
+func scanResults(ctx context.Context, rows *sql.Rows, runnerId string) ([]*models.Result, *models.ResponseError) {
 	defer rows.Close()
 
 	results := make([]*models.Result, 0)
-	var id, raceResult, location string
-	var position, year int
+	var id, raceId, status, location string
+	var reason sql.NullString
+	var raceResult models.RaceTime
+	var position sql.NullInt64
+	var distanceMeters, year, version int
 
 	for rows.Next() {
-		err := rows.Scan(&id, &raceResult, &location, &position, &year)
+		err := rows.Scan(&id, &raceId, &status, &reason, &raceResult, &distanceMeters, &location, &position, &year, &version)
 		if err != nil {
-			return nil, &models.ResponseError{
-				Message: err.Error(),
-				Status:  http.StatusInternalServerError,
-			}
+			return nil, queryError(ctx, err)
 		}
 		result := &models.Result{
-			ID:         id,
-			RunnerID:   runnerId,
-			RaceResult: raceResult,
-			Location:   location,
-			Position:   position,
-			Year:       year,
+			ID:             id,
+			RunnerID:       runnerId,
+			RaceID:         raceId,
+			Status:         status,
+			Reason:         reason.String,
+			RaceResult:     raceResult,
+			DistanceMeters: distanceMeters,
+			Location:       location,
+			Position:       int(position.Int64),
+			Year:           year,
+			Version:        version,
 		}
 		results = append(results, result)
 	}
 
-	err = rows.Err()
+	err := rows.Err()
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	return results, nil
 }
 
-func (rr ResultsRepository) QueryGetPersonalBestResults(runnerId string) (string, *models.ResponseError) {
+// QueryRecomputeRunnerBests replaces the bests of the runner by the fastest
+// of their finished results per distance and season and returns how many
+// there are afterwards.
+func (rr ResultsRepository) QueryRecomputeRunnerBests(ctx context.Context, runnerId string, season models.Season) (int64, *models.ResponseError) {
 	query := `
-		SELECT
-			MIN(race_result)
-		FROM
-			results
+		DELETE FROM
+			runner_bests
 		WHERE
 			runner_id = $1`
-	row := rr.dbHandler.QueryRow(query, runnerId)
-
-	var raceResult string
-	err := row.Scan(&raceResult)
+	_, err := rr.dbHandler.ExecContext(ctx, query, runnerId)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", nil
-		}
-		return "", &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return 0, queryError(ctx, err)
 	}
 
-	return raceResult, nil
+	qb := &queryBuilder{}
+	qb.where("results.runner_id = ?", runnerId)
+
+	return rr.insertBests(ctx, qb, season)
 }
 
-func (rr ResultsRepository) QueryGetSeasonBestResults(runnerId string, year int) (string, *models.ResponseError) {
+// insertBests inserts the fastest finished results selected by qb per
+// runner, distance and season. The season of a result is the one its event
+// took place in.
+func (rr ResultsRepository) insertBests(ctx context.Context, qb *queryBuilder, season models.Season) (int64, *models.ResponseError) {
+	qb.where("results.status = ?", models.RESULT_STATUS_FINISHED)
+	seasonStart := qb.arg(season.String())
+
 	query := `
+		INSERT INTO
+			runner_bests(runner_id, distance_meters, season, race_result)
 		SELECT
-			MIN(race_result)
+			results.runner_id, results.distance_meters, seasons.season, MIN(results.race_result)
 		FROM
 			results
-		WHERE
-			runner_id = $1
-			AND
-			year = $2`
-	row := rr.dbHandler.QueryRow(query, runnerId, year)
+			JOIN races ON races.id = results.race_id
+			JOIN events ON events.id = races.event_id
+			CROSS JOIN LATERAL (
+				SELECT
+					EXTRACT(YEAR FROM events.event_date)::integer -
+					CASE WHEN to_char(events.event_date, 'MM-DD') < ` + seasonStart + ` THEN 1 ELSE 0 END AS season
+			) seasons
+		` + qb.whereClause() + `
+		GROUP BY
+			results.runner_id, results.distance_meters, seasons.season`
+	res, err := rr.dbHandler.ExecContext(ctx, query, qb.args...)
 
-	var raceResult string
-	err := row.Scan(&raceResult)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", nil
-		}
-		return "", &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return 0, queryError(ctx, err)
 	}
 
-	return raceResult, nil
+	return rowsAffected, nil
+}
+
+func nullInt(value int) sql.NullInt64 {
+	return sql.NullInt64{
+		Int64: int64(value),
+		Valid: value != 0,
+	}
 }
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runners/models"
+)
+
+const (
+	SORT_PERSONAL_BEST = "personal_best"
+	SORT_SEASON_BEST   = "season_best"
+	SORT_LAST_NAME     = "last_name"
+	SORT_AGE           = "age"
+)
+
+var runnersSortColumns = map[string]func(filter *RunnersFilter) string{
+	SORT_PERSONAL_BEST: personalBestColumn,
+	SORT_SEASON_BEST:   seasonBestColumn,
+	SORT_LAST_NAME:     func(*RunnersFilter) string { return "last_name" },
+	SORT_AGE:           func(*RunnersFilter) string { return "age" },
+}
+
+// RunnersFilter selects runners. Distance and Season choose the bests runners
+// are returned and sorted with. Inactive runners are left out unless
+// IncludeInactive is set or IsActive asks for them.
+type RunnersFilter struct {
+	Country         string
+	Year            int
+	IsActive        *bool
+	IncludeInactive bool
+	MinAge          int
+	MaxAge          int
+	Distance        int
+	Season          int
+	SortBy          string
+	Descending      bool
+	Limit           int
+	Cursor          string
+}
+
+func IsRunnersSortField(field string) bool {
+	_, ok := runnersSortColumns[field]
+
+	return ok
+}
+
+func personalBestColumn(filter *RunnersFilter) string {
+	return fmt.Sprintf(`(
+			SELECT MIN(race_result) FROM runner_bests
+			WHERE runner_bests.runner_id = runners.id AND runner_bests.distance_meters = %d
+		)`, filter.Distance)
+}
+
+func seasonBestColumn(filter *RunnersFilter) string {
+	return fmt.Sprintf(`(
+			SELECT race_result FROM runner_bests
+			WHERE runner_bests.runner_id = runners.id AND runner_bests.distance_meters = %d AND runner_bests.season = %d
+		)`, filter.Distance, filter.Season)
+}
+
+// runnersCursor points at the last runner of a page. It stores the sort key
+// and distance together with the value so a cursor can not be reused with
+// another ordering.
+type runnersCursor struct {
+	SortBy     string  `json:"s"`
+	Descending bool    `json:"d,omitempty"`
+	Distance   int     `json:"m,omitempty"`
+	Value      *string `json:"v"`
+	ID         string  `json:"id"`
+}
+
+func encodeRunnersCursor(cursor *runnersCursor) string {
+	cursorJson, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(cursorJson)
+}
+
+func decodeRunnersCursor(token string, filter *RunnersFilter) (*runnersCursor, *models.ResponseError) {
+	invalidCursor := &models.ResponseError{
+		Message: "Invalid cursor",
+		Status:  http.StatusBadRequest,
+		Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+		Fields:  []*models.FieldError{{Field: "cursor", Message: "Invalid cursor"}},
+	}
+
+	cursorJson, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil {
+		return nil, invalidCursor
+	}
+
+	var cursor runnersCursor
+	err = json.Unmarshal(cursorJson, &cursor)
+
+	if err != nil || cursor.ID == "" {
+		return nil, invalidCursor
+	}
+
+	if cursor.SortBy != filter.SortBy || cursor.Descending != filter.Descending || cursor.Distance != filter.Distance {
+		return nil, invalidCursor
+	}
+
+	return &cursor, nil
+}
+
+func newRunnersFilterQuery(filter *RunnersFilter) *queryBuilder {
+	qb := &queryBuilder{}
+
+	if filter.Country != "" {
+		qb.where("country = ?", filter.Country)
+	}
+
+	if filter.Year != 0 {
+		qb.where("EXISTS (SELECT 1 FROM results WHERE results.runner_id = runners.id AND results.year = ?)", filter.Year)
+	}
+
+	if filter.IsActive != nil {
+		qb.where("is_active = ?", *filter.IsActive)
+	} else if !filter.IncludeInactive {
+		qb.where("is_active")
+	}
+
+	if filter.MinAge != 0 {
+		qb.where("age >= ?", filter.MinAge)
+	}
+
+	if filter.MaxAge != 0 {
+		qb.where("age <= ?", filter.MaxAge)
+	}
+
+	return qb
+}
+
+// whereAfterCursor restricts the query to runners ordered after the cursor.
+// Runners without a value for the sort column are always ordered last.
+func (qb *queryBuilder) whereAfterCursor(sortColumn string, cursor *runnersCursor) *queryBuilder {
+	if cursor.Value == nil {
+		return qb.where(sortColumn+" IS NULL AND id > ?", cursor.ID)
+	}
+
+	comparator := ">"
+	if cursor.Descending {
+		comparator = "<"
+	}
+
+	return qb.where(
+		"("+sortColumn+" "+comparator+" ? OR ("+sortColumn+" = ? AND id > ?) OR "+sortColumn+" IS NULL)",
+		*cursor.Value, *cursor.Value, cursor.ID,
+	)
+}
@@ -1,49 +1,55 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"runners/models"
 )
 
 type RunnersRepository struct {
-	dbHandler   *sql.DB
-	transaction *sql.Tx
+	dbHandler dbExecutor
 }
 
 func NewRunnersRepository(dbHandler *sql.DB) *RunnersRepository {
 	return &RunnersRepository{
-		dbHandler: dbHandler,
+		dbHandler: traced(dbHandler),
 	}
 }
 
-func (rr *RunnersRepository) SetTransaction(transaction *sql.Tx) {
-	rr.transaction = transaction
-}
-
-func (rr *RunnersRepository) GetTransaction() *sql.Tx {
-	return rr.transaction
-}
-
-func (rr *RunnersRepository) ClearTransaction() {
-	rr.transaction = nil
-}
-
-func (rr RunnersRepository) QueryCreateRunner(runner *models.Runner) *sql.Row {
+func (rr RunnersRepository) QueryCreateRunner(ctx context.Context, runner *models.Runner) (*models.Runner, *models.ResponseError) {
 	query := `
 		INSERT INTO
 			runners(first_name, last_name, age, country)
 		VALUES
 			($1, $2, $3, $4)
 		RETURNING
-			id, is_active`
+			id, is_active, version`
+
+	row := rr.dbHandler.QueryRowContext(ctx, query, runner.FirstName, runner.LastName, runner.Age, runner.Country)
+
+	var runnerId string
+	var isActive bool
+	var version int
+	err := row.Scan(&runnerId, &isActive, &version)
 
-	row := rr.dbHandler.QueryRow(query, runner.FirstName, runner.LastName, runner.Age, runner.Country)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
 
-	return row
+	return &models.Runner{
+		ID:        runnerId,
+		FirstName: runner.FirstName,
+		LastName:  runner.LastName,
+		Age:       runner.Age,
+		IsActive:  isActive,
+		Country:   runner.Country,
+		Version:   version,
+	}, nil
 }
 
-func (rr RunnersRepository) QueryUpdateRunner(runner *models.Runner) (sql.Result, *models.ResponseError) {
+func (rr RunnersRepository) QueryUpdateRunner(ctx context.Context, runner *models.Runner) (sql.Result, *models.ResponseError) {
 	query := `
 		UPDATE
 			runners
@@ -51,302 +57,356 @@ func (rr RunnersRepository) QueryUpdateRunner(runner *models.Runner) (sql.Result
 			first_name = $1,
 			last_name = $2,
 			age = $3,
-			country = $4
+			country = $4,
+			version = version + 1
 		WHERE
 			id = $5`
-	res, err := rr.dbHandler.Exec(query, runner.FirstName, runner.LastName, runner.Age, runner.Country, runner.ID)
+	res, err := rr.dbHandler.ExecContext(ctx, query, runner.FirstName, runner.LastName, runner.Age, runner.Country, runner.ID)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	return res, nil
 }
 
-func (rr RunnersRepository) QueryUpdateRunnerResult(runner *models.Runner) (sql.Result, *models.ResponseError) {
+// QuerySetRunnerActive deactivates or reactivates the runner. Inactive
+// runners keep their results.
+func (rr RunnersRepository) QuerySetRunnerActive(ctx context.Context, runnerId string, isActive bool) (sql.Result, *models.ResponseError) {
 	query := `
 		UPDATE
 			runners
 		SET
-			personal_best = $1,
-			season_best = $2
+			is_active = $1,
+			version = version + 1
 		WHERE
-			id = $3`
-	res, err := rr.transaction.Exec(query, runner.PersonalBest, runner.SeasonBest, runner.ID)
+			id = $2`
+	res, err := rr.dbHandler.ExecContext(ctx, query, isActive, runnerId)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	return res, nil
 }
 
-func (rr RunnersRepository) QueryDeleteRunner(runnerId string) (sql.Result, *models.ResponseError) {
+// QueryDeleteRunner deletes the runner together with their bests. Their
+// results have to be deleted first.
+func (rr RunnersRepository) QueryDeleteRunner(ctx context.Context, runnerId string) (sql.Result, *models.ResponseError) {
 	query := `
-		UPDATE
+		DELETE FROM
 			runners
-		SET
-			is_active = 'false'
 		WHERE
 			id = $1`
-	res, err := rr.dbHandler.Exec(query, runnerId)
+	res, err := rr.dbHandler.ExecContext(ctx, query, runnerId)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	return res, nil
 }
 
-func (rr RunnersRepository) QueryGetRunner(runnerId string) (*models.Runner, *models.ResponseError) {
+func (rr RunnersRepository) QueryGetRunner(ctx context.Context, runnerId string) (*models.Runner, *models.ResponseError) {
 	query := `
 		SELECT
-			*
+			id, first_name, last_name, age, is_active, country, version
 		FROM
 			runners
 		WHERE
 			id = $1`
-	row := rr.dbHandler.QueryRow(query, runnerId)
 
-	var id, firstName, lastName, country string
-	var personalBest, seasonBest sql.NullString
-	var age int
-	var isActive bool
-	err := row.Scan(&id, &firstName, &lastName, &age, &isActive, &country, &personalBest, &seasonBest)
+	return rr.queryGetRunner(ctx, query, runnerId)
+}
+
+// QueryGetRunnerForUpdate locks the runner row until the surrounding
+// transaction ends, so concurrent result writes update the bests one by one.
+func (rr RunnersRepository) QueryGetRunnerForUpdate(ctx context.Context, runnerId string) (*models.Runner, *models.ResponseError) {
+	query := `
+		SELECT
+			id, first_name, last_name, age, is_active, country, version
+		FROM
+			runners
+		WHERE
+			id = $1
+		FOR UPDATE`
+
+	return rr.queryGetRunner(ctx, query, runnerId)
+}
+
+// QueryIncrementRunnerVersion bumps the version of the runner after their
+// results or bests changed.
+func (rr RunnersRepository) QueryIncrementRunnerVersion(ctx context.Context, runnerId string) *models.ResponseError {
+	query := `
+		UPDATE
+			runners
+		SET
+			version = version + 1
+		WHERE
+			id = $1`
+	_, err := rr.dbHandler.ExecContext(ctx, query, runnerId)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return queryError(ctx, err)
 	}
 
-	return &models.Runner{
-		ID:           id,
-		FirstName:    firstName,
-		LastName:     lastName,
-		Age:          age,
-		IsActive:     isActive,
-		Country:      country,
-		PersonalBest: personalBest.String,
-		SeasonBest:   seasonBest.String,
-	}, nil
+	return nil
 }
 
-func (rr RunnersRepository) QueryGetAllRunners() ([]*models.Runner, *models.ResponseError) {
+// QueryGetAllRunnerIDs returns the ids of all runners, active or not, in
+// the order they are locked in.
+func (rr RunnersRepository) QueryGetAllRunnerIDs(ctx context.Context) ([]string, *models.ResponseError) {
 	query := `
 		SELECT
-			*
+			id
 		FROM
-			runners`
-	rows, err := rr.dbHandler.Query(query)
+			runners
+		ORDER BY
+			id`
+	rows, err := rr.dbHandler.QueryContext(ctx, query)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	defer rows.Close()
 
-	runners := make([]*models.Runner, 0)
-	var id, firstName, lastName, country string
-	var personalBest, seasonBest sql.NullString
-	var age int
-	var isActive bool
+	runnerIds := make([]string, 0)
 
 	for rows.Next() {
-		err := rows.Scan(&id, &firstName, &lastName, &age, &isActive, &country, &personalBest, &seasonBest)
+		var runnerId string
+
+		err := rows.Scan(&runnerId)
 		if err != nil {
-			return nil, &models.ResponseError{
-				Message: err.Error(),
-				Status:  http.StatusInternalServerError,
-			}
+			return nil, queryError(ctx, err)
 		}
 
-		runner := &models.Runner{
-			ID:           id,
-			FirstName:    firstName,
-			LastName:     lastName,
-			Age:          age,
-			IsActive:     isActive,
-			Country:      country,
-			PersonalBest: personalBest.String,
-			SeasonBest:   seasonBest.String,
-		}
-		runners = append(runners, runner)
+		runnerIds = append(runnerIds, runnerId)
 	}
 
 	err = rows.Err()
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
+		return nil, queryError(ctx, err)
+	}
+
+	return runnerIds, nil
+}
+
+func (rr RunnersRepository) queryGetRunner(ctx context.Context, query string, runnerId string) (*models.Runner, *models.ResponseError) {
+	row := rr.dbHandler.QueryRowContext(ctx, query, runnerId)
+
+	var id, firstName, lastName, country string
+	var age, version int
+	var isActive bool
+	err := row.Scan(&id, &firstName, &lastName, &age, &isActive, &country, &version)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
 		}
+		return nil, queryError(ctx, err)
 	}
 
-	return runners, nil
+	return &models.Runner{
+		ID:        id,
+		FirstName: firstName,
+		LastName:  lastName,
+		Age:       age,
+		IsActive:  isActive,
+		Country:   country,
+		Version:   version,
+	}, nil
 }
 
-func (rr RunnersRepository) QueryGetRunnersByCountry(country string) ([]*models.Runner, *models.ResponseError) {
+// QueryGetRunnerBests returns the personal best of the runner at every
+// distance they have results for, with the best of season next to it.
+func (rr RunnersRepository) QueryGetRunnerBests(ctx context.Context, runnerId string, season int) ([]*models.RunnerBest, *models.ResponseError) {
 	query := `
 		SELECT
-			id,
-			first_name,
-			last_name,
-			age,
-			personal_best,
-			season_best
+			distance_meters,
+			MIN(race_result),
+			MIN(race_result) FILTER (WHERE season = $2)
 		FROM
-			runners
+			runner_bests
 		WHERE
-			country = $1
-			AND
-			is_active = 'true'
+			runner_id = $1
+		GROUP BY
+			distance_meters
 		ORDER BY
-			personal_best
-		LIMIT
-			10`
-	rows, err := rr.dbHandler.Query(query, country)
+			distance_meters`
+	rows, err := rr.dbHandler.QueryContext(ctx, query, runnerId, season)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	defer rows.Close()
 
-	runners := make([]*models.Runner, 0)
-	var id, firstName, lastName string
-	var personalBest, seasonBest sql.NullString
-	var age int
+	bests := make([]*models.RunnerBest, 0)
 
 	for rows.Next() {
-		err := rows.Scan(&id, &firstName, &lastName, &age, &personalBest, &seasonBest)
+		best := &models.RunnerBest{}
+
+		err := rows.Scan(&best.DistanceMeters, &best.PersonalBest, &best.SeasonBest)
 		if err != nil {
-			return nil, &models.ResponseError{
-				Message: err.Error(),
-				Status:  http.StatusInternalServerError,
-			}
+			return nil, queryError(ctx, err)
 		}
 
-		runner := &models.Runner{
-			ID:           id,
-			FirstName:    firstName,
-			LastName:     lastName,
-			Age:          age,
-			IsActive:     true,
-			Country:      country,
-			PersonalBest: personalBest.String,
-			SeasonBest:   seasonBest.String,
-		}
-		runners = append(runners, runner)
+		best.Distance = models.DistanceName(best.DistanceMeters)
+		bests = append(bests, best)
 	}
 
 	err = rows.Err()
 	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return bests, nil
+}
+
+func (rr RunnersRepository) QueryGetRunnersBatch(ctx context.Context, filter *RunnersFilter) (*models.RunnersBatch, *models.ResponseError) {
+	sortColumnOf, ok := runnersSortColumns[filter.SortBy]
+
+	if !ok {
 		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
+			Message: "Invalid sort field",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+			Fields:  []*models.FieldError{{Field: "sort", Message: "Invalid sort field"}},
 		}
 	}
 
-	return runners, nil
-}
+	sortColumn := sortColumnOf(filter)
+	qb := newRunnersFilterQuery(filter)
 
-func (rr RunnersRepository) QueryGetRunnersByYear(year int) ([]*models.Runner, *models.ResponseError) {
-	query := `
+	countQuery := `
+		SELECT
+			COUNT(*)
+		FROM
+			runners
+		` + qb.whereClause()
+
+	var totalCount int
+	err := rr.dbHandler.QueryRowContext(ctx, countQuery, qb.args...).Scan(&totalCount)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	pageQb := qb.clone()
+
+	if filter.Cursor != "" {
+		cursor, responseErr := decodeRunnersCursor(filter.Cursor, filter)
+
+		if responseErr != nil {
+			return nil, responseErr
+		}
+
+		pageQb.whereAfterCursor(sortColumn, cursor)
+	}
+
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+
+	// One runner more than requested is loaded to find out if there is a next page
+	query := fmt.Sprintf(`
 		SELECT
-			runners.id,
-			runners.first_name,
-			runners.last_name,
-			runners.age,
-			runners.is_active,
-			runners.country,
-			runners.personal_best,
-			runners.season_best,
-			results.race_result
+			id,
+			first_name,
+			last_name,
+			age,
+			is_active,
+			country,
+			version,
+			%s,
+			%s,
+			%s::text
 		FROM
 			runners
-		INNER JOIN (
-			SELECT
-				runner_id,
-				MIN(race_result) as race_result
-			FROM
-				results
-			WHERE
-				year = $1
-			GROUP BY
-				runner_id
-			) results
-		ON
-			runners.id = results.runner_id
+		%s
 		ORDER BY
-			results.race_result
+			%s %s NULLS LAST,
+			id
 		LIMIT
-			10`
-	rows, err := rr.dbHandler.Query(query, year)
+			%s`, personalBestColumn(filter), seasonBestColumn(filter), sortColumn,
+		pageQb.whereClause(), sortColumn, direction, pageQb.arg(filter.Limit+1))
+
+	rows, err := rr.dbHandler.QueryContext(ctx, query, pageQb.args...)
 
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return nil, queryError(ctx, err)
 	}
 
 	defer rows.Close()
 
 	runners := make([]*models.Runner, 0)
+	var lastSortValue sql.NullString
 	var id, firstName, lastName, country string
-	var personalBest, seasonBest sql.NullString
-	var age int
+	var personalBest, seasonBest models.RaceTime
+	var sortValue sql.NullString
+	var age, version int
 	var isActive bool
+	hasNextPage := false
 
 	for rows.Next() {
-		err := rows.Scan(&id, &firstName, &lastName, &age, &isActive, &country, &personalBest, &seasonBest)
+		if len(runners) == filter.Limit {
+			hasNextPage = true
+			break
+		}
+
+		err := rows.Scan(&id, &firstName, &lastName, &age, &isActive, &country, &version, &personalBest, &seasonBest, &sortValue)
 		if err != nil {
-			return nil, &models.ResponseError{
-				Message: err.Error(),
-				Status:  http.StatusInternalServerError,
-			}
+			return nil, queryError(ctx, err)
 		}
 
 		runner := &models.Runner{
-			ID:           id,
-			FirstName:    firstName,
-			LastName:     lastName,
-			Age:          age,
-			IsActive:     isActive,
-			Country:      country,
-			PersonalBest: personalBest.String,
-			SeasonBest:   seasonBest.String,
+			ID:        id,
+			FirstName: firstName,
+			LastName:  lastName,
+			Age:       age,
+			IsActive:  isActive,
+			Country:   country,
+			Version:   version,
 		}
 
+		if personalBest != 0 {
+			runner.Bests = []*models.RunnerBest{{
+				DistanceMeters: filter.Distance,
+				Distance:       models.DistanceName(filter.Distance),
+				PersonalBest:   personalBest,
+				SeasonBest:     seasonBest,
+			}}
+		}
 		runners = append(runners, runner)
+		lastSortValue = sortValue
 	}
 
 	err = rows.Err()
 	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
+		return nil, queryError(ctx, err)
+	}
+
+	batch := &models.RunnersBatch{
+		Runners:    runners,
+		TotalCount: totalCount,
+	}
+
+	if hasNextPage {
+		cursor := &runnersCursor{
+			SortBy:     filter.SortBy,
+			Descending: filter.Descending,
+			Distance:   filter.Distance,
+			ID:         runners[len(runners)-1].ID,
 		}
+
+		if lastSortValue.Valid {
+			cursor.Value = &lastSortValue.String
+		}
+
+		batch.NextCursor = encodeRunnersCursor(cursor)
 	}
 
-	return runners, nil
+	return batch, nil
 }
@@ -0,0 +1,281 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"runners/models"
+	"time"
+)
+
+type SessionsRepository struct {
+	dbHandler dbExecutor
+}
+
+func NewSessionsRepository(dbHandler *sql.DB) *SessionsRepository {
+	return &SessionsRepository{
+		dbHandler: traced(dbHandler),
+	}
+}
+
+func (sr SessionsRepository) QueryCreateSession(ctx context.Context, userId string, userAgent string, ipAddress string, expiresAt time.Time) (string, *models.ResponseError) {
+	query := `
+		INSERT INTO
+			sessions(user_id, user_agent, ip_address, expires_at)
+		VALUES
+			($1, $2, $3, $4)
+		RETURNING
+			id`
+	row := sr.dbHandler.QueryRowContext(ctx, query, userId, userAgent, ipAddress, expiresAt)
+
+	var sessionId string
+	err := row.Scan(&sessionId)
+
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+
+	return sessionId, nil
+}
+
+// QueryGetActiveSessionLastSeen returns when the session was last used, nil
+// if it is not valid any more, i.e. revoked or expired.
+func (sr SessionsRepository) QueryGetActiveSessionLastSeen(ctx context.Context, sessionId string) (*time.Time, *models.ResponseError) {
+	query := `
+		SELECT
+			last_seen_at
+		FROM
+			sessions
+		WHERE
+			id = $1
+			AND
+			revoked_at IS NULL
+			AND
+			expires_at > now()`
+	row := sr.dbHandler.QueryRowContext(ctx, query, sessionId)
+
+	var lastSeenAt time.Time
+	err := row.Scan(&lastSeenAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return &lastSeenAt, nil
+}
+
+// QueryTouchSession records activity on the session unless it was recorded
+// less than interval ago, also by a concurrent request.
+func (sr SessionsRepository) QueryTouchSession(ctx context.Context, sessionId string, interval time.Duration) *models.ResponseError {
+	query := `
+		UPDATE
+			sessions
+		SET
+			last_seen_at = now()
+		WHERE
+			id = $1
+			AND
+			last_seen_at < now() - $2 * interval '1 second'`
+	_, err := sr.dbHandler.ExecContext(ctx, query, sessionId, interval.Seconds())
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+func (sr SessionsRepository) QueryExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) *models.ResponseError {
+	query := `
+		UPDATE
+			sessions
+		SET
+			expires_at = $1,
+			last_seen_at = now()
+		WHERE
+			id = $2`
+	_, err := sr.dbHandler.ExecContext(ctx, query, expiresAt, sessionId)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+func (sr SessionsRepository) QueryGetUserSessions(ctx context.Context, userId string) ([]*models.Session, *models.ResponseError) {
+	query := `
+		SELECT
+			id, user_agent, ip_address, created_at, last_seen_at, expires_at
+		FROM
+			sessions
+		WHERE
+			user_id = $1
+			AND
+			revoked_at IS NULL
+			AND
+			expires_at > now()
+		ORDER BY
+			last_seen_at DESC`
+	rows, err := sr.dbHandler.QueryContext(ctx, query, userId)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	sessions := make([]*models.Session, 0)
+	var id, userAgent, ipAddress string
+	var createdAt, lastSeenAt, expiresAt time.Time
+
+	for rows.Next() {
+		err := rows.Scan(&id, &userAgent, &ipAddress, &createdAt, &lastSeenAt, &expiresAt)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		session := &models.Session{
+			ID:         id,
+			UserAgent:  userAgent,
+			IPAddress:  ipAddress,
+			CreatedAt:  createdAt,
+			LastSeenAt: lastSeenAt,
+			ExpiresAt:  expiresAt,
+		}
+		sessions = append(sessions, session)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return sessions, nil
+}
+
+func (sr SessionsRepository) QueryRevokeSession(ctx context.Context, sessionId string, userId string) (int64, *models.ResponseError) {
+	query := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = now()
+		WHERE
+			id = $1
+			AND
+			user_id = $2
+			AND
+			revoked_at IS NULL`
+	res, err := sr.dbHandler.ExecContext(ctx, query, sessionId, userId)
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// QueryRevokeUserSessions revokes every session of the user except the one
+// with exceptSessionId, pass an empty string to revoke all of them.
+func (sr SessionsRepository) QueryRevokeUserSessions(ctx context.Context, userId string, exceptSessionId string) *models.ResponseError {
+	query := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = now()
+		WHERE
+			user_id = $1
+			AND
+			id::text <> $2
+			AND
+			revoked_at IS NULL`
+	_, err := sr.dbHandler.ExecContext(ctx, query, userId, exceptSessionId)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+func (sr SessionsRepository) QueryCreateRefreshToken(ctx context.Context, sessionId string, tokenHash string, expiresAt time.Time) *models.ResponseError {
+	query := `
+		INSERT INTO
+			refresh_tokens(session_id, token_hash, expires_at)
+		VALUES
+			($1, $2, $3)`
+	_, err := sr.dbHandler.ExecContext(ctx, query, sessionId, tokenHash, expiresAt)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
+
+func (sr SessionsRepository) QueryGetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*models.RefreshToken, *models.ResponseError) {
+	query := `
+		SELECT
+			refresh_tokens.id,
+			refresh_tokens.session_id,
+			sessions.user_id,
+			refresh_tokens.expires_at,
+			refresh_tokens.used_at,
+			sessions.revoked_at
+		FROM
+			refresh_tokens
+		INNER JOIN
+			sessions
+		ON
+			sessions.id = refresh_tokens.session_id
+		WHERE
+			refresh_tokens.token_hash = $1
+		FOR UPDATE`
+	row := sr.dbHandler.QueryRowContext(ctx, query, tokenHash)
+
+	var id, sessionId, userId string
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(&id, &sessionId, &userId, &expiresAt, &usedAt, &revokedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return &models.RefreshToken{
+		ID:        id,
+		SessionID: sessionId,
+		UserID:    userId,
+		ExpiresAt: expiresAt,
+		Used:      usedAt.Valid,
+		Revoked:   revokedAt.Valid,
+	}, nil
+}
+
+func (sr SessionsRepository) QueryMarkRefreshTokenUsed(ctx context.Context, refreshTokenId string) *models.ResponseError {
+	query := `
+		UPDATE
+			refresh_tokens
+		SET
+			used_at = now()
+		WHERE
+			id = $1`
+	_, err := sr.dbHandler.ExecContext(ctx, query, refreshTokenId)
+
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"runners/tracing"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedExecutor creates a span for every SQL statement, named after the
+// repository method executing it.
+type tracedExecutor struct {
+	dbExecutor dbExecutor
+}
+
+func traced(executor dbExecutor) dbExecutor {
+	return &tracedExecutor{
+		dbExecutor: executor,
+	}
+}
+
+func (te *tracedExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatementSpan(ctx, query)
+	defer span.End()
+
+	result, err := te.dbExecutor.ExecContext(ctx, query, args...)
+	endStatementSpan(span, err)
+
+	if err == nil {
+		rowsAffected, err := result.RowsAffected()
+
+		if err == nil {
+			span.SetAttributes(attribute.Int64("db.rows_affected", rowsAffected))
+		}
+	}
+
+	return result, err
+}
+
+func (te *tracedExecutor) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatementSpan(ctx, query)
+	defer span.End()
+
+	rows, err := te.dbExecutor.QueryContext(ctx, query, args...)
+	endStatementSpan(span, err)
+
+	return rows, err
+}
+
+func (te *tracedExecutor) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatementSpan(ctx, query)
+	defer span.End()
+
+	row := te.dbExecutor.QueryRowContext(ctx, query, args...)
+	endStatementSpan(span, row.Err())
+
+	return row
+}
+
+func startStatementSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, statementName(),
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", strings.Join(strings.Fields(query), " ")),
+	)
+}
+
+func endStatementSpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// statementName returns the name of the repository method that called the
+// tracedExecutor, e.g. RunnersRepository.QueryGetRunner.
+func statementName() string {
+	pc, _, _, ok := runtime.Caller(3)
+
+	if !ok {
+		return "sql"
+	}
+
+	name := runtime.FuncForPC(pc).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+
+	return strings.TrimPrefix(name, "repositories.")
+}
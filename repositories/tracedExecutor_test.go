@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedExecutorSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	dbHandler, mock, _ := sqlmock.New()
+	defer dbHandler.Close()
+
+	mock.ExpectExec("UPDATE runners").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	runnersRepository := NewRunnersRepository(dbHandler)
+	_, responseErr := runnersRepository.QuerySetRunnerActive(context.Background(), "1", false)
+
+	require.Nil(t, responseErr)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "RunnersRepository.QuerySetRunnerActive", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int64("db.rows_affected", 1))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("db.statement", "UPDATE runners SET is_active = $1, version = version + 1 WHERE id = $2"))
+}
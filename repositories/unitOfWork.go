@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"runners/logging"
+	"runners/models"
+)
+
+// dbExecutor is satisfied by both *sql.DB and *sql.Tx so the same repository
+// code can run inside or outside of a transaction.
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Repositories struct {
+	Runners     *RunnersRepository
+	Results     *ResultsRepository
+	Users       *UsersRepository
+	Sessions    *SessionsRepository
+	Audit       *AuditRepository
+	Events      *EventsRepository
+	Idempotency *IdempotencyRepository
+}
+
+type UnitOfWork struct {
+	dbHandler *sql.DB
+}
+
+func NewUnitOfWork(dbHandler *sql.DB) *UnitOfWork {
+	return &UnitOfWork{
+		dbHandler: dbHandler,
+	}
+}
+
+// Execute runs work inside its own transaction with a freshly created set of
+// repositories bound to it. The transaction is committed if work succeeds and
+// rolled back otherwise.
+func (uow *UnitOfWork) Execute(ctx context.Context, work func(repos *Repositories) *models.ResponseError) *models.ResponseError {
+	transaction, err := uow.dbHandler.BeginTx(ctx, &sql.TxOptions{})
+
+	if err != nil {
+		if deadlineExceeded(ctx, err) {
+			return queryError(ctx, err)
+		}
+
+		logging.FromContext(ctx).Error("Failed to start transaction", "error", err)
+
+		return &models.ResponseError{
+			Message: "Failed to start transaction",
+			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
+		}
+	}
+
+	defer transaction.Rollback()
+
+	executor := traced(transaction)
+	responseErr := work(&Repositories{
+		Runners:     &RunnersRepository{dbHandler: executor},
+		Results:     &ResultsRepository{dbHandler: executor},
+		Users:       &UsersRepository{dbHandler: executor},
+		Sessions:    &SessionsRepository{dbHandler: executor},
+		Audit:       &AuditRepository{dbHandler: executor},
+		Events:      &EventsRepository{dbHandler: executor},
+		Idempotency: &IdempotencyRepository{dbHandler: executor},
+	})
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	err = transaction.Commit()
+
+	if err != nil {
+		if deadlineExceeded(ctx, err) {
+			return queryError(ctx, err)
+		}
+
+		logging.FromContext(ctx).Error("Failed to commit transaction", "error", err)
+
+		return &models.ResponseError{
+			Message: "Failed to commit transaction",
+			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return nil
+}
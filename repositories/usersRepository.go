@@ -1,83 +1,267 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"runners/models"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type UsersRepository struct {
-	dbHandler *sql.DB
+	dbHandler dbExecutor
 }
 
 func NewUsersRepository(dbHandler *sql.DB) *UsersRepository {
 	return &UsersRepository{
-		dbHandler: dbHandler,
+		dbHandler: traced(dbHandler),
 	}
 }
 
-func (ur UsersRepository) QueryGetUser(username string) *sql.Row {
+func (ur UsersRepository) QueryCreateUser(ctx context.Context, username string, passwordHash string, role string) (*models.User, *models.ResponseError) {
+	query := `
+				INSERT INTO
+					users(username, user_password, user_role)
+				VALUES
+					($1, $2, $3)
+				RETURNING
+					id, disabled, created_at`
+	row := ur.dbHandler.QueryRowContext(ctx, query, username, passwordHash, role)
+
+	var id string
+	var disabled bool
+	var createdAt time.Time
+	err := row.Scan(&id, &disabled, &createdAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			return nil, &models.ResponseError{
+				Message: "Username already exists",
+				Status:  http.StatusConflict,
+				Code:    models.ERROR_CODE_USERNAME_TAKEN,
+				Fields:  []*models.FieldError{{Field: "username", Message: "Username already exists"}},
+			}
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return &models.User{
+		ID:        id,
+		Username:  username,
+		Role:      role,
+		Disabled:  disabled,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (ur UsersRepository) QueryGetUser(ctx context.Context, username string) (*models.User, *models.ResponseError) {
 	query := `
 				SELECT
-					user_password
+					id, username, user_password, user_role, disabled, created_at
 				FROM
 					users
 				WHERE
 					username = $1`
-	row := ur.dbHandler.QueryRow(query, username)
+	row := ur.dbHandler.QueryRowContext(ctx, query, username)
 
-	return row
+	return scanUser(ctx, row)
 }
 
-func (ur UsersRepository) QueryGetUserRole(accessToken string) *sql.Row {
+func (ur UsersRepository) QueryGetUserById(ctx context.Context, userId string) (*models.User, *models.ResponseError) {
 	query := `
 				SELECT
-					user_role
+					id, username, user_password, user_role, disabled, created_at
 				FROM
 					users
 				WHERE
-					access_token = $1`
-	row := ur.dbHandler.QueryRow(query, accessToken)
+					id = $1`
+	row := ur.dbHandler.QueryRowContext(ctx, query, userId)
 
-	return row
+	return scanUser(ctx, row)
 }
 
-func (ur UsersRepository) QuerySetAccessToken(accessToken string, username string) *models.ResponseError {
+func (ur UsersRepository) QueryGetAllUsers(ctx context.Context) ([]*models.User, *models.ResponseError) {
+	query := `
+				SELECT
+					id, username, user_role, disabled, created_at
+				FROM
+					users
+				ORDER BY
+					username`
+	rows, err := ur.dbHandler.QueryContext(ctx, query)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	var id, username, role string
+	var disabled bool
+	var createdAt time.Time
+
+	for rows.Next() {
+		err := rows.Scan(&id, &username, &role, &disabled, &createdAt)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		user := &models.User{
+			ID:        id,
+			Username:  username,
+			Role:      role,
+			Disabled:  disabled,
+			CreatedAt: createdAt,
+		}
+		users = append(users, user)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return users, nil
+}
+
+func (ur UsersRepository) QueryUpdateUser(ctx context.Context, userId string, update *models.UserUpdate) (int64, *models.ResponseError) {
 	query := `
 				UPDATE
 					users
 				SET
-					access_token = $1
+					user_role = $1,
+					disabled = $2
 				WHERE
-					username = $2`
-	_, err := ur.dbHandler.Exec(query, accessToken, username)
+					id = $3`
+	res, err := ur.dbHandler.ExecContext(ctx, query, update.Role, update.Disabled, userId)
 
 	if err != nil {
-		return &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return 0, queryError(ctx, err)
 	}
 
-	return nil
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return rowsAffected, nil
 }
 
-func (ur UsersRepository) QueryRemoveAccessToken(accessToken string) *models.ResponseError {
+func (ur UsersRepository) QueryUpdatePassword(ctx context.Context, userId string, passwordHash string) *models.ResponseError {
 	query := `
 				UPDATE
 					users
 				SET
-					access_token = ''
+					user_password = $1
 				WHERE
-					access_token = $1`
-	_, err := ur.dbHandler.Exec(query, accessToken)
+					id = $2`
+	_, err := ur.dbHandler.ExecContext(ctx, query, passwordHash, userId)
 
 	if err != nil {
-		return &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+		return queryError(ctx, err)
 	}
 
 	return nil
 }
+
+func (ur UsersRepository) QueryDeleteUser(ctx context.Context, userId string) (int64, *models.ResponseError) {
+	query := `
+				DELETE FROM
+					users
+				WHERE
+					id = $1`
+	res, err := ur.dbHandler.ExecContext(ctx, query, userId)
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return rowsAffected, nil
+}
+
+func (ur UsersRepository) QueryRoleExists(ctx context.Context, role string) (bool, *models.ResponseError) {
+	query := `
+				SELECT
+					EXISTS (SELECT 1 FROM roles WHERE name = $1)`
+	row := ur.dbHandler.QueryRowContext(ctx, query, role)
+
+	var exists bool
+	err := row.Scan(&exists)
+
+	if err != nil {
+		return false, queryError(ctx, err)
+	}
+
+	return exists, nil
+}
+
+func (ur UsersRepository) QueryGetRolePermissions(ctx context.Context, role string) ([]string, *models.ResponseError) {
+	query := `
+				SELECT
+					permission
+				FROM
+					role_permissions
+				WHERE
+					role = $1`
+	rows, err := ur.dbHandler.QueryContext(ctx, query, role)
+
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	permissions := make([]string, 0)
+	var permission string
+
+	for rows.Next() {
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return permissions, nil
+}
+
+func scanUser(ctx context.Context, row *sql.Row) (*models.User, *models.ResponseError) {
+	var id, username, password, role string
+	var disabled bool
+	var createdAt time.Time
+	err := row.Scan(&id, &username, &password, &role, &disabled, &createdAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+
+	return &models.User{
+		ID:        id,
+		Username:  username,
+		Password:  password,
+		Role:      role,
+		Disabled:  disabled,
+		CreatedAt: createdAt,
+	}, nil
+}
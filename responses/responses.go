@@ -0,0 +1,100 @@
+package responses
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runners/logging"
+	"runners/models"
+	"runners/tracing"
+	"strings"
+)
+
+const PROBLEM_CONTENT_TYPE = "application/problem+json"
+
+// INTERNAL_ERROR_DETAIL replaces the message of internal errors, which are
+// logged where they occur but never shown to clients.
+const INTERNAL_ERROR_DETAIL = "An internal error occurred"
+
+// WriteJSON writes body as the JSON response with the given status.
+func WriteJSON(w http.ResponseWriter, r *http.Request, status int, body any) {
+	responseJson, err := json.Marshal(body)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode response", "error", err)
+		WriteError(w, r, &models.ResponseError{
+			Message: "Failed to encode response",
+			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJson)
+}
+
+// WriteError writes responseErr as an application/problem+json response.
+func WriteError(w http.ResponseWriter, r *http.Request, responseErr *models.ResponseError) {
+	responseJson, err := json.Marshal(NewProblem(r, responseErr))
+
+	if err != nil {
+		http.Error(w, INTERNAL_ERROR_DETAIL, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", PROBLEM_CONTENT_TYPE)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(responseErr.Status)
+	w.Write(responseJson)
+}
+
+// BadRequestBody is the error for request bodies that can not be decoded.
+// Only the reason of values rejected by our own types is passed on.
+func BadRequestBody(err error) *models.ResponseError {
+	message := "Error while reading request body"
+
+	var raceTimeErr *models.RaceTimeError
+	if errors.As(err, &raceTimeErr) {
+		message = raceTimeErr.Error()
+	}
+
+	return &models.ResponseError{
+		Message: message,
+		Status:  http.StatusBadRequest,
+		Code:    models.ERROR_CODE_INVALID_REQUEST_BODY,
+	}
+}
+
+func NewProblem(r *http.Request, responseErr *models.ResponseError) *models.Problem {
+	problem := &models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(responseErr.Status),
+		Status:    responseErr.Status,
+		Detail:    responseErr.Message,
+		Instance:  r.URL.Path,
+		Code:      responseErr.Code,
+		Errors:    responseErr.Fields,
+		RequestID: models.RequestIDFromContext(r.Context()),
+		TraceID:   tracing.TraceID(r.Context()),
+	}
+
+	if problem.Code == "" {
+		problem.Code = defaultCode(responseErr.Status)
+	}
+
+	if responseErr.Status == http.StatusInternalServerError {
+		problem.Code = models.ERROR_CODE_INTERNAL
+		problem.Detail = INTERNAL_ERROR_DETAIL
+		problem.Errors = nil
+	}
+
+	return problem
+}
+
+// defaultCode is derived from the status for errors without a specific code,
+// e.g. NOT_FOUND.
+func defaultCode(status int) string {
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
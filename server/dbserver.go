@@ -1,12 +1,19 @@
 package server
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"os"
+	"runners/metrics"
+	"runners/migrations"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+const MAX_PING_BACKOFF = 30 * time.Second
+
 func InitDatabase(config *viper.Viper) *sql.DB {
 	connectionString := config.GetString("database.connection_string")
 	maxIdleConnections := config.GetInt("database.max_idle_connections")
@@ -15,24 +22,74 @@ func InitDatabase(config *viper.Viper) *sql.DB {
 	driverName := config.GetString("database.driver_name")
 
 	if connectionString == "" {
-		log.Fatalf("Database connections tring is missing")
+		slog.Error("Database connection string is missing")
+		os.Exit(1)
 	}
 
 	dbHandler, err := sql.Open(driverName, connectionString)
 
 	if err != nil {
-		log.Fatalf("Error while initializing database: %v", err)
+		slog.Error("Error while initializing database", "error", err)
+		os.Exit(1)
 	}
 
 	dbHandler.SetMaxIdleConns(maxIdleConnections)
 	dbHandler.SetMaxOpenConns(maxOpenConnections)
 	dbHandler.SetConnMaxLifetime(connectionsMaxLifetime)
 
-	err = dbHandler.Ping()
+	err = pingWithRetry(dbHandler, config.GetInt("database.ping_attempts"), config.GetDuration("database.ping_backoff"))
 
 	if err != nil {
-		log.Fatalf("Error while validation database: %v", err)
+		slog.Error("Error while validation database", "error", err)
+		os.Exit(1)
+	}
+
+	metrics.RegisterDBStats(dbHandler, "runners_db")
+
+	if config.GetBool("database.auto_migrate") {
+		migrateDatabase(dbHandler)
 	}
 
 	return dbHandler
 }
+
+// pingWithRetry pings the database up to attempts times, doubling the wait
+// between two attempts up to MAX_PING_BACKOFF, so the app survives starting
+// up before the database does.
+func pingWithRetry(dbHandler *sql.DB, attempts int, backoff time.Duration) error {
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		err = dbHandler.Ping()
+
+		if err == nil || attempt >= attempts {
+			return err
+		}
+
+		slog.Warn("Database not reachable, retrying",
+			"attempt", attempt,
+			"attempts", attempts,
+			"backoff", backoff,
+			"error", err)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, MAX_PING_BACKOFF)
+	}
+}
+
+func migrateDatabase(dbHandler *sql.DB) {
+	migrator, err := migrations.NewMigrator(dbHandler)
+
+	if err != nil {
+		slog.Error("Error while loading migrations", "error", err)
+		os.Exit(1)
+	}
+
+	count, err := migrator.Up(context.Background())
+
+	if err != nil {
+		slog.Error("Error while migrating database", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Migrated database", "applied_migrations", count)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runners/logging"
+	"runners/migrations"
+	"time"
+)
+
+const (
+	HEALTH_STATUS_UP   = "up"
+	HEALTH_STATUS_DOWN = "down"
+)
+
+// Failed checks report these instead of the error, which may name the
+// database host, user or driver internals.
+const (
+	HEALTH_ERROR_UNREACHABLE     = "unreachable"
+	HEALTH_ERROR_SCHEMA_UNKNOWN  = "schema version unknown"
+	HEALTH_ERROR_SCHEMA_MISMATCH = "schema version mismatch"
+	HEALTH_ERROR_NOT_SERVING     = "not started or shutting down"
+)
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthHandler struct {
+	dbHandler    *sql.DB
+	migrator     *migrations.Migrator
+	readiness    *Readiness
+	checkTimeout time.Duration
+}
+
+func NewHealthHandler(dbHandler *sql.DB, migrator *migrations.Migrator, readiness *Readiness, checkTimeout time.Duration) *HealthHandler {
+	return &HealthHandler{
+		dbHandler:    dbHandler,
+		migrator:     migrator,
+		readiness:    readiness,
+		checkTimeout: checkTimeout,
+	}
+}
+
+// Healthz reports that the process is up without looking at dependencies.
+func (hh HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthResponse(w, &HealthResponse{
+		Status: HEALTH_STATUS_UP,
+	})
+}
+
+// Readyz reports whether the app can serve requests, with the result of
+// every dependency check.
+func (hh HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), hh.checkTimeout)
+	defer cancel()
+
+	response := &HealthResponse{
+		Status: HEALTH_STATUS_UP,
+		Checks: map[string]*HealthCheck{
+			"database":   healthCheck(ctx, "database", hh.dbHandler.PingContext(ctx), HEALTH_ERROR_UNREACHABLE),
+			"migrations": hh.checkMigrations(ctx),
+			"lifecycle":  healthCheck(ctx, "lifecycle", hh.checkLifecycle(), HEALTH_ERROR_NOT_SERVING),
+		},
+	}
+
+	for _, check := range response.Checks {
+		if check.Status != HEALTH_STATUS_UP {
+			response.Status = HEALTH_STATUS_DOWN
+		}
+	}
+
+	writeHealthResponse(w, response)
+}
+
+func (hh HealthHandler) checkMigrations(ctx context.Context) *HealthCheck {
+	version, err := hh.migrator.CurrentVersion(ctx)
+
+	if err != nil {
+		return healthCheck(ctx, "migrations", err, HEALTH_ERROR_SCHEMA_UNKNOWN)
+	}
+
+	if version != hh.migrator.LatestVersion() {
+		err = fmt.Errorf("schema version is %d, expected %d", version, hh.migrator.LatestVersion())
+		return healthCheck(ctx, "migrations", err, HEALTH_ERROR_SCHEMA_MISMATCH)
+	}
+
+	return healthCheck(ctx, "migrations", nil, "")
+}
+
+func (hh HealthHandler) checkLifecycle() error {
+	if !hh.readiness.IsReady() {
+		return errors.New("readiness is not set")
+	}
+
+	return nil
+}
+
+// healthCheck logs the error of a failed check and reports message instead,
+// as /readyz is served without authentication.
+func healthCheck(ctx context.Context, check string, err error, message string) *HealthCheck {
+	if err != nil {
+		logging.FromContext(ctx).Warn("Health check failed", "check", check, "error", err)
+
+		return &HealthCheck{
+			Status: HEALTH_STATUS_DOWN,
+			Error:  message,
+		}
+	}
+
+	return &HealthCheck{
+		Status: HEALTH_STATUS_UP,
+	}
+}
+
+func writeHealthResponse(w http.ResponseWriter, response *HealthResponse) {
+	status := http.StatusOK
+
+	if response.Status != HEALTH_STATUS_UP {
+		status = http.StatusServiceUnavailable
+	}
+
+	responseJson, err := json.Marshal(response)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(responseJson)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runners/migrations"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyzUp(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer dbHandler.Close()
+
+	migrator, err := migrations.NewMigrator(dbHandler)
+	require.NoError(t, err)
+
+	mock.ExpectPing()
+	mock.ExpectQuery("SELECT COALESCE").WillReturnRows(
+		sqlmock.NewRows([]string{"version"}).AddRow(migrator.LatestVersion()),
+	)
+
+	readiness := &Readiness{}
+	readiness.SetReady(true)
+
+	response := serveReadyz(t, NewHealthHandler(dbHandler, migrator, readiness, time.Second), http.StatusOK)
+
+	assert.Equal(t, HEALTH_STATUS_UP, response.Status)
+	assert.Len(t, response.Checks, 3)
+}
+
+func TestReadyzDown(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer dbHandler.Close()
+
+	migrator, err := migrations.NewMigrator(dbHandler)
+	require.NoError(t, err)
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectQuery("SELECT COALESCE").WillReturnRows(
+		sqlmock.NewRows([]string{"version"}).AddRow(migrator.LatestVersion() - 1),
+	)
+
+	// Readiness is false until the app has started
+	response := serveReadyz(t, NewHealthHandler(dbHandler, migrator, &Readiness{}, time.Second), http.StatusServiceUnavailable)
+
+	assert.Equal(t, HEALTH_STATUS_DOWN, response.Status)
+	assert.Equal(t, HEALTH_ERROR_UNREACHABLE, response.Checks["database"].Error)
+	assert.Equal(t, HEALTH_ERROR_SCHEMA_MISMATCH, response.Checks["migrations"].Error)
+	assert.Equal(t, HEALTH_ERROR_NOT_SERVING, response.Checks["lifecycle"].Error)
+}
+
+func TestReadyzHidesErrors(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer dbHandler.Close()
+
+	migrator, err := migrations.NewMigrator(dbHandler)
+	require.NoError(t, err)
+
+	mock.ExpectPing()
+	mock.ExpectQuery("SELECT COALESCE").WillReturnError(errors.New(`pq: password authentication failed for user "runners"`))
+
+	readiness := &Readiness{}
+	readiness.SetReady(true)
+
+	response := serveReadyz(t, NewHealthHandler(dbHandler, migrator, readiness, time.Second), http.StatusServiceUnavailable)
+
+	assert.Equal(t, HEALTH_ERROR_SCHEMA_UNKNOWN, response.Checks["migrations"].Error)
+}
+
+func TestPingWithRetry(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer dbHandler.Close()
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing()
+
+	err := pingWithRetry(dbHandler, 3, time.Millisecond)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPingWithRetryGivesUp(t *testing.T) {
+	dbHandler, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer dbHandler.Close()
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	err := pingWithRetry(dbHandler, 2, time.Millisecond)
+
+	assert.EqualError(t, err, "connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func serveReadyz(t *testing.T, healthHandler *HealthHandler, expectedStatus int) *HealthResponse {
+	request, _ := http.NewRequest("GET", "/readyz", nil)
+	recorder := httptest.NewRecorder()
+	healthHandler.Readyz(recorder, request)
+
+	require.Equal(t, expectedStatus, recorder.Result().StatusCode)
+
+	var response HealthResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+
+	require.NoError(t, err)
+
+	return &response
+}
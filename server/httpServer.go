@@ -1,10 +1,16 @@
 package server
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"os"
 	"runners/controllers"
+	"runners/middleware"
+	"runners/migrations"
+	"runners/models"
 	"runners/repositories"
 	"runners/services"
 
@@ -17,36 +23,118 @@ type HttpServer struct {
 	runnersController *controllers.RunnersController
 	resultsController *controllers.ResultsController
 	usersController   *controllers.UsersController
+	auditController   *controllers.AuditController
+	eventsController  *controllers.EventsController
+	bestsController   *controllers.BestsController
 }
 
-func InitHttpServer(config *viper.Viper, dbHandler *sql.DB) HttpServer {
+func InitHttpServer(config *viper.Viper, dbHandler *sql.DB, readiness *Readiness, jobs *services.Jobs) HttpServer {
 	runnersRepository := repositories.NewRunnersRepository(dbHandler)
 	resultsRepository := repositories.NewResultsRepository(dbHandler)
 	usersRepository := repositories.NewUsersRepository(dbHandler)
-	runnersService := services.NewRunnersService(runnersRepository, resultsRepository)
-	resultsService := services.NewResultsService(resultsRepository, runnersRepository)
-	usersService := services.NewUsersService(usersRepository)
-	runnersController := controllers.NewRunnersController(runnersService, usersService)
-	resultsController := controllers.NewResultsController(resultsService, usersService)
-	usersController := controllers.NewUsersController(usersService)
+	sessionsRepository := repositories.NewSessionsRepository(dbHandler)
+	auditRepository := repositories.NewAuditRepository(dbHandler)
+	eventsRepository := repositories.NewEventsRepository(dbHandler)
+	unitOfWork := repositories.NewUnitOfWork(dbHandler)
+	season := InitSeason(config)
+	idempotencyWindow := InitIdempotencyWindow(config)
+	runnersService := services.NewRunnersService(runnersRepository, resultsRepository, unitOfWork, season, idempotencyWindow)
+	resultsService := services.NewResultsService(unitOfWork, season, idempotencyWindow)
+	auditService := services.NewAuditService(auditRepository)
+	eventsService := services.NewEventsService(eventsRepository, unitOfWork, season)
+	bestsService := services.NewBestsService(repositories.NewBestsRepository(dbHandler), runnersRepository, unitOfWork, season, jobs)
+	tokenIssuer, err := services.NewTokenIssuer(
+		config.GetString("auth.signing_algorithm"),
+		config.GetString("auth.signing_key"),
+		config.GetDuration("auth.access_token_ttl"),
+		config.GetDuration("auth.refresh_token_ttl"),
+	)
+
+	if err != nil {
+		slog.Error("Error while initializing token issuer", "error", err)
+		os.Exit(1)
+	}
+
+	usersService := services.NewUsersService(usersRepository, sessionsRepository, unitOfWork, tokenIssuer)
+	trustedProxies, err := controllers.ParseTrustedProxies(config.GetStringSlice("http.trusted_proxies"))
+
+	if err != nil {
+		slog.Error("Error while reading trusted proxies", "error", err)
+		os.Exit(1)
+	}
+
+	runnersController := controllers.NewRunnersController(runnersService)
+	resultsController := controllers.NewResultsController(resultsService)
+	usersController := controllers.NewUsersController(usersService, trustedProxies)
+	auditController := controllers.NewAuditController(auditService)
+	eventsController := controllers.NewEventsController(eventsService)
+	bestsController := controllers.NewBestsController(bestsService)
+	authorizer := middleware.NewAuthorizer(usersService)
+	migrator, err := migrations.NewMigrator(dbHandler)
+
+	if err != nil {
+		slog.Error("Error while loading migrations", "error", err)
+		os.Exit(1)
+	}
+
+	healthHandler := NewHealthHandler(dbHandler, migrator, readiness, config.GetDuration("http.health_check_timeout"))
 
 	router := http.NewServeMux()
 
-	router.HandleFunc("POST /runner", runnersController.CreateRunner)
-	router.HandleFunc("PUT /runner", runnersController.UpdateRunner)
-	router.HandleFunc("DELETE /runner/{id}", runnersController.DeleteRunner)
-	router.HandleFunc("GET /runner/{id}", runnersController.GetRunner)
-	router.HandleFunc("GET /runner", runnersController.GetRunnersBatch)
+	router.HandleFunc("GET /healthz", healthHandler.Healthz)
+	router.HandleFunc("GET /readyz", healthHandler.Readyz)
 
-	router.HandleFunc("POST /result", resultsController.CreateResult)
-	router.HandleFunc("DELETE /result/{id}", resultsController.DeleteResult)
+	router.Handle("POST /runner", authorizer.Protect(models.PERMISSION_RUNNERS_WRITE, runnersController.CreateRunner))
+	router.Handle("PUT /runner", authorizer.Protect(models.PERMISSION_RUNNERS_WRITE, runnersController.UpdateRunner))
+	router.Handle("DELETE /runner/{id}", authorizer.Protect(models.PERMISSION_RUNNERS_DELETE, runnersController.DeleteRunner))
+	router.Handle("POST /runner/{id}/deactivate", authorizer.Protect(models.PERMISSION_RUNNERS_DELETE, runnersController.DeactivateRunner))
+	router.Handle("POST /runner/{id}/reactivate", authorizer.Protect(models.PERMISSION_RUNNERS_WRITE, runnersController.ReactivateRunner))
+	router.Handle("GET /runner/{id}", authorizer.Protect(models.PERMISSION_RUNNERS_READ, runnersController.GetRunner))
+	router.Handle("GET /runner", authorizer.Protect(models.PERMISSION_RUNNERS_READ, runnersController.GetRunnersBatch))
+
+	router.Handle("POST /result", authorizer.Protect(models.PERMISSION_RESULTS_WRITE, resultsController.CreateResult))
+	router.Handle("PUT /result/{id}", authorizer.Protect(models.PERMISSION_RESULTS_WRITE, resultsController.UpdateResult))
+	router.Handle("DELETE /result/{id}", authorizer.Protect(models.PERMISSION_RESULTS_DELETE, resultsController.DeleteResult))
+
+	router.Handle("POST /event", authorizer.Protect(models.PERMISSION_EVENTS_WRITE, eventsController.CreateEvent))
+	router.Handle("GET /event", authorizer.Protect(models.PERMISSION_EVENTS_READ, eventsController.GetEvents))
+	router.Handle("GET /event/{id}", authorizer.Protect(models.PERMISSION_EVENTS_READ, eventsController.GetEvent))
+	router.Handle("PUT /event/{id}", authorizer.Protect(models.PERMISSION_EVENTS_WRITE, eventsController.UpdateEvent))
+	router.Handle("DELETE /event/{id}", authorizer.Protect(models.PERMISSION_EVENTS_DELETE, eventsController.DeleteEvent))
+	router.Handle("GET /event/{id}/races", authorizer.Protect(models.PERMISSION_EVENTS_READ, eventsController.GetRaces))
+	router.Handle("POST /event/{id}/races", authorizer.Protect(models.PERMISSION_EVENTS_WRITE, eventsController.CreateRace))
+	router.Handle("PUT /event/{id}/races/{raceId}", authorizer.Protect(models.PERMISSION_EVENTS_WRITE, eventsController.UpdateRace))
+	router.Handle("DELETE /event/{id}/races/{raceId}", authorizer.Protect(models.PERMISSION_EVENTS_DELETE, eventsController.DeleteRace))
 
 	router.HandleFunc("POST /login", usersController.Login)
-	router.HandleFunc("POST /logout", usersController.Logout)
+	router.HandleFunc("POST /token/refresh", usersController.RefreshToken)
+	router.Handle("POST /logout", authorizer.Protect("", usersController.Logout))
+	router.Handle("GET /me/sessions", authorizer.Protect("", usersController.GetSessions))
+	router.Handle("DELETE /me/sessions/{id}", authorizer.Protect("", usersController.RevokeSession))
+	router.Handle("PUT /me/password", authorizer.Protect("", usersController.ChangePassword))
+
+	router.Handle("POST /user", authorizer.Protect(models.PERMISSION_USERS_WRITE, usersController.CreateUser))
+	router.Handle("GET /user", authorizer.Protect(models.PERMISSION_USERS_READ, usersController.GetUsers))
+	router.Handle("GET /user/{id}", authorizer.Protect(models.PERMISSION_USERS_READ, usersController.GetUser))
+	router.Handle("PUT /user/{id}", authorizer.Protect(models.PERMISSION_USERS_WRITE, usersController.UpdateUser))
+	router.Handle("DELETE /user/{id}", authorizer.Protect(models.PERMISSION_USERS_DELETE, usersController.DeleteUser))
+
+	router.Handle("GET /audit", authorizer.Protect(models.PERMISSION_AUDIT_READ, auditController.GetAuditEntries))
+
+	router.Handle("POST /admin/recompute-bests", authorizer.Protect(models.PERMISSION_BESTS_RECOMPUTE, bestsController.RecomputeBests))
+	router.Handle("GET /admin/recompute-bests/{id}", authorizer.Protect(models.PERMISSION_BESTS_RECOMPUTE, bestsController.GetBestsRecomputation))
+
+	handler := middleware.Chain(router,
+		middleware.Metrics(router),
+		middleware.Tracing(router),
+		middleware.RequestID,
+		middleware.Logging(router),
+		middleware.QueryTimeout(config.GetDuration("database.query_timeout")),
+	)
 
 	server := &http.Server{
 		Addr:    config.GetString("http.server_address"),
-		Handler: router,
+		Handler: handler,
 	}
 
 	return HttpServer{
@@ -55,12 +143,26 @@ func InitHttpServer(config *viper.Viper, dbHandler *sql.DB) HttpServer {
 		runnersController: runnersController,
 		resultsController: resultsController,
 		usersController:   usersController,
+		auditController:   auditController,
+		eventsController:  eventsController,
+		bestsController:   bestsController,
 	}
 }
 
-func (hs HttpServer) Start() {
+// Start blocks until the server fails or is shut down. Shutting down is not
+// reported as an error.
+func (hs HttpServer) Start() error {
 	err := hs.server.ListenAndServe()
-	if err != nil {
-		log.Fatalf("Error while starting HTTP server: %v", err)
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
+
+	return err
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until
+// ctx is done.
+func (hs HttpServer) Shutdown(ctx context.Context) error {
+	return hs.server.Shutdown(ctx)
 }
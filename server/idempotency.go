@@ -0,0 +1,27 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"runners/models"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// InitIdempotencyWindow reads how long responses are kept for their
+// Idempotency-Key from idempotency.window, which defaults to 24 hours.
+func InitIdempotencyWindow(config *viper.Viper) time.Duration {
+	if !config.IsSet("idempotency.window") {
+		return models.DEFAULT_IDEMPOTENCY_WINDOW
+	}
+
+	window := config.GetDuration("idempotency.window")
+
+	if window <= 0 {
+		slog.Error("Error while reading idempotency window, it has to be positive", "window", window)
+		os.Exit(1)
+	}
+
+	return window
+}
@@ -1,12 +1,42 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
 )
 
-func InitPrometheus() {
-	http.Handle("/metrics", promhttp.Handler())
-	http.ListenAndServe(":9000", nil)
+type PrometheusServer struct {
+	server *http.Server
+}
+
+func InitPrometheus(config *viper.Viper) *PrometheusServer {
+	router := http.NewServeMux()
+	router.Handle("/metrics", promhttp.Handler())
+
+	return &PrometheusServer{
+		server: &http.Server{
+			Addr:    config.GetString("prometheus.server_address"),
+			Handler: router,
+		},
+	}
+}
+
+// Start blocks until the server fails or is shut down. Shutting down is not
+// reported as an error.
+func (ps *PrometheusServer) Start() error {
+	err := ps.server.ListenAndServe()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (ps *PrometheusServer) Shutdown(ctx context.Context) error {
+	return ps.server.Shutdown(ctx)
 }
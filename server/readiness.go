@@ -0,0 +1,22 @@
+package server
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// Readiness tracks whether the app should receive traffic. It is false while
+// starting up and as soon as shutting down begins.
+type Readiness struct {
+	ready atomic.Bool
+}
+
+func (r *Readiness) SetReady(ready bool) {
+	if r.ready.Swap(ready) != ready {
+		slog.Info("Readiness changed", "ready", ready)
+	}
+}
+
+func (r *Readiness) IsReady() bool {
+	return r.ready.Load()
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"runners/models"
+
+	"github.com/spf13/viper"
+)
+
+// InitSeason reads the start of the season from bests.season_start, which
+// defaults to January 1.
+func InitSeason(config *viper.Viper) models.Season {
+	seasonStart := config.GetString("bests.season_start")
+
+	if seasonStart == "" {
+		seasonStart = models.DEFAULT_SEASON_START
+	}
+
+	season, err := models.ParseSeasonStart(seasonStart)
+
+	if err != nil {
+		slog.Error("Error while reading season start", "error", err)
+		os.Exit(1)
+	}
+
+	return season
+}
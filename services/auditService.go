@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runners/logging"
+	"runners/models"
+	"runners/repositories"
+	"runners/tracing"
+	"strconv"
+	"time"
+)
+
+const DEFAULT_AUDIT_LIMIT = 100
+const MAX_AUDIT_LIMIT = 1000
+
+type AuditService struct {
+	auditRepository *repositories.AuditRepository
+}
+
+func NewAuditService(auditRepository *repositories.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepository: auditRepository,
+	}
+}
+
+func (as AuditService) GetAuditEntries(ctx context.Context, params *models.AuditParams) ([]*models.AuditEntry, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "AuditService.GetAuditEntries")
+	defer span.End()
+
+	filter, responseErr := parseAuditFilter(params)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return as.auditRepository.QueryGetAuditEntries(ctx, filter)
+}
+
+func parseAuditFilter(params *models.AuditParams) (*repositories.AuditFilter, *models.ResponseError) {
+	filter := &repositories.AuditFilter{
+		EntityID: params.EntityID,
+		Actor:    params.Actor,
+		Limit:    DEFAULT_AUDIT_LIMIT,
+	}
+
+	if params.From != "" {
+		from, err := time.Parse(time.RFC3339, params.From)
+
+		if err != nil {
+			return nil, &models.ResponseError{
+				Message: "Invalid from",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "from", Message: "Invalid from"}},
+			}
+		}
+
+		filter.From = &from
+	}
+
+	if params.To != "" {
+		to, err := time.Parse(time.RFC3339, params.To)
+
+		if err != nil || (filter.From != nil && !to.After(*filter.From)) {
+			return nil, &models.ResponseError{
+				Message: "Invalid to",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "to", Message: "Invalid to"}},
+			}
+		}
+
+		filter.To = &to
+	}
+
+	if params.Limit != "" {
+		limit, err := strconv.Atoi(params.Limit)
+
+		if err != nil || limit <= 0 || limit > MAX_AUDIT_LIMIT {
+			return nil, &models.ResponseError{
+				Message: "Invalid limit",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "limit", Message: "Invalid limit"}},
+			}
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// recordAudit writes an audit entry for a mutation in the transaction of
+// repos, so it is only kept if the mutation is committed.
+func recordAudit(ctx context.Context, repos *repositories.Repositories, action string, entity string, entityId string, before any, after any) *models.ResponseError {
+	beforeJson, afterJson, err := auditDiff(before, after)
+
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to encode audit entry", "error", err)
+
+		return &models.ResponseError{
+			Message: "Failed to encode audit entry",
+			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
+		}
+	}
+
+	entry := &models.AuditEntry{
+		Actor:     models.AUDIT_ACTOR_SYSTEM,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityId,
+		Before:    beforeJson,
+		After:     afterJson,
+		RequestID: models.RequestIDFromContext(ctx),
+	}
+
+	principal := models.PrincipalFromContext(ctx)
+
+	if principal != nil {
+		entry.ActorID = principal.UserID
+		entry.Actor = principal.Username
+	}
+
+	return repos.Audit.QueryCreateAuditEntry(ctx, entry)
+}
+
+// auditDiff returns the JSON representations of before and after reduced to
+// the fields that differ between them. Either one may be nil for creations
+// and deletions.
+func auditDiff(before any, after any) (json.RawMessage, json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	afterFields, err := auditFields(after)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, beforeValue := range beforeFields {
+		afterValue, ok := afterFields[key]
+
+		if ok && reflect.DeepEqual(beforeValue, afterValue) {
+			delete(beforeFields, key)
+			delete(afterFields, key)
+		}
+	}
+
+	beforeJson, err := marshalAuditFields(beforeFields)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	afterJson, err := marshalAuditFields(afterFields)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return beforeJson, afterJson, nil
+}
+
+func auditFields(entity any) (map[string]any, error) {
+	if entity == nil {
+		return nil, nil
+	}
+
+	entityJson, err := json.Marshal(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	err = json.Unmarshal(entityJson, &fields)
+
+	return fields, err
+}
+
+func marshalAuditFields(fields map[string]any) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"net/http"
+	"runners/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditDiffUpdate(t *testing.T) {
+	before := &models.Runner{
+		ID:        "1",
+		FirstName: "Adam",
+		LastName:  "Smith",
+		Age:       30,
+		IsActive:  true,
+		Country:   "USA",
+	}
+	after := *before
+	after.Age = 31
+
+	beforeJson, afterJson, err := auditDiff(before, &after)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"age": 30}`, string(beforeJson))
+	assert.JSONEq(t, `{"age": 31}`, string(afterJson))
+}
+
+func TestAuditDiffCreate(t *testing.T) {
+	result := &models.Result{
+		ID:             "1",
+		RunnerID:       "2",
+		RaceID:         "3",
+		Status:         models.RESULT_STATUS_FINISHED,
+		RaceResult:     models.RaceTime(2*time.Hour + 10*time.Minute),
+		DistanceMeters: models.DISTANCE_MARATHON,
+		Location:       "Berlin",
+		Year:           2024,
+		Version:        1,
+	}
+
+	beforeJson, afterJson, err := auditDiff(nil, result)
+
+	require.NoError(t, err)
+	assert.Nil(t, beforeJson)
+	assert.JSONEq(t, `{"id": "1", "runner_id": "2", "race_id": "3", "status": "finished", "race_result": "02:10:00", "distance_meters": 42195, "location": "Berlin", "year": 2024, "version": 1}`, string(afterJson))
+}
+
+func TestParseAuditFilterDefaults(t *testing.T) {
+	filter, responseErr := parseAuditFilter(&models.AuditParams{})
+
+	assert.Nil(t, responseErr)
+	assert.Equal(t, DEFAULT_AUDIT_LIMIT, filter.Limit)
+	assert.Nil(t, filter.From)
+	assert.Nil(t, filter.To)
+}
+
+func TestParseAuditFilterInvalidRange(t *testing.T) {
+	_, responseErr := parseAuditFilter(&models.AuditParams{
+		From: "2024-06-01T00:00:00Z",
+		To:   "2024-05-01T00:00:00Z",
+	})
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, "Invalid to", responseErr.Message)
+	assert.Equal(t, http.StatusBadRequest, responseErr.Status)
+}
+
+func TestParseAuditFilterInvalidLimit(t *testing.T) {
+	_, responseErr := parseAuditFilter(&models.AuditParams{
+		Limit: "5000",
+	})
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, "Invalid limit", responseErr.Message)
+	assert.Equal(t, http.StatusBadRequest, responseErr.Status)
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runners/logging"
+	"runners/models"
+	"runners/repositories"
+	"runners/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BESTS_RECOMPUTATION_PROGRESS_INTERVAL is the number of runners after which
+// the progress of a recomputation is stored.
+const BESTS_RECOMPUTATION_PROGRESS_INTERVAL = 100
+
+const (
+	BESTS_RECOMPUTATION_INTERRUPTED = "Interrupted by shutdown"
+	BESTS_RECOMPUTATION_ABANDONED   = "Abandoned by a stopped instance"
+)
+
+type BestsService struct {
+	bestsRepository   *repositories.BestsRepository
+	runnersRepository *repositories.RunnersRepository
+	unitOfWork        *repositories.UnitOfWork
+	season            models.Season
+	jobs              *Jobs
+}
+
+// NewBestsService returns the service with jobs running the recomputations
+// started in the background. Commands that only recompute synchronously
+// pass nil.
+func NewBestsService(
+	bestsRepository *repositories.BestsRepository,
+	runnersRepository *repositories.RunnersRepository,
+	unitOfWork *repositories.UnitOfWork,
+	season models.Season,
+	jobs *Jobs) *BestsService {
+	return &BestsService{
+		bestsRepository:   bestsRepository,
+		runnersRepository: runnersRepository,
+		unitOfWork:        unitOfWork,
+		season:            season,
+		jobs:              jobs,
+	}
+}
+
+// StartRecomputeAllBests starts rebuilding the bests of all runners in the
+// background and returns the recomputation to follow its progress.
+func (bs BestsService) StartRecomputeAllBests(ctx context.Context) (*models.BestsRecomputation, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "BestsService.StartRecomputeAllBests")
+	defer span.End()
+
+	unlock, responseErr := bs.lockRecomputation(ctx)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	recomputation, runnerIds, responseErr := bs.createRecomputation(ctx)
+
+	if responseErr != nil {
+		unlock()
+		return nil, responseErr
+	}
+
+	started := *recomputation
+
+	// The rebuild outlives the request and its query timeout, but not the app
+	bs.jobs.Go(ctx, func(ctx context.Context) {
+		defer unlock()
+		bs.recompute(ctx, recomputation, runnerIds)
+	})
+
+	return &started, nil
+}
+
+// RecomputeAllBests rebuilds the bests of all runners from their results and
+// returns when it is done.
+func (bs BestsService) RecomputeAllBests(ctx context.Context) (*models.BestsRecomputation, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "BestsService.RecomputeAllBests")
+	defer span.End()
+
+	unlock, responseErr := bs.lockRecomputation(ctx)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	defer unlock()
+
+	recomputation, runnerIds, responseErr := bs.createRecomputation(ctx)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	responseErr = bs.recompute(ctx, recomputation, runnerIds)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return recomputation, nil
+}
+
+// FailUnfinishedRecomputations marks the recomputations as failed that are
+// still running after an instance stopped without finishing them. A
+// recomputation running on another instance holds the lock and is left alone.
+func (bs BestsService) FailUnfinishedRecomputations(ctx context.Context) *models.ResponseError {
+	unlock, responseErr := bs.bestsRepository.QueryTryLockBestsRecomputation(ctx)
+
+	if responseErr != nil || unlock == nil {
+		return responseErr
+	}
+
+	defer unlock()
+
+	failed, responseErr := bs.bestsRepository.QueryFailUnfinishedBestsRecomputations(ctx, BESTS_RECOMPUTATION_ABANDONED)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	if failed > 0 {
+		slog.Warn("Marked unfinished bests recomputations as failed", "recomputations", failed)
+	}
+
+	return nil
+}
+
+func (bs BestsService) GetBestsRecomputation(ctx context.Context, recomputationId string) (*models.BestsRecomputation, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "BestsService.GetBestsRecomputation")
+	defer span.End()
+
+	err := uuid.Validate(recomputationId)
+
+	if err != nil {
+		return nil, &models.ResponseError{
+			Message: "Invalid recomputation ID",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_RECOMPUTATION_ID,
+		}
+	}
+
+	recomputation, responseErr := bs.bestsRepository.QueryGetBestsRecomputation(ctx, recomputationId)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	if recomputation == nil {
+		return nil, &models.ResponseError{
+			Message: "Recomputation not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_RECOMPUTATION_NOT_FOUND,
+		}
+	}
+
+	return recomputation, nil
+}
+
+// lockRecomputation takes the lock held while the bests are recomputed and
+// answers 409 if another instance holds it.
+func (bs BestsService) lockRecomputation(ctx context.Context) (func(), *models.ResponseError) {
+	unlock, responseErr := bs.bestsRepository.QueryTryLockBestsRecomputation(ctx)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	if unlock == nil {
+		return nil, &models.ResponseError{
+			Message: "A recomputation of the bests is already running",
+			Status:  http.StatusConflict,
+			Code:    models.ERROR_CODE_RECOMPUTATION_RUNNING,
+		}
+	}
+
+	return unlock, nil
+}
+
+func (bs BestsService) createRecomputation(ctx context.Context) (*models.BestsRecomputation, []string, *models.ResponseError) {
+	runnerIds, responseErr := bs.runnersRepository.QueryGetAllRunnerIDs(ctx)
+
+	if responseErr != nil {
+		return nil, nil, responseErr
+	}
+
+	recomputation, responseErr := bs.bestsRepository.QueryCreateBestsRecomputation(ctx, bs.season.Of(time.Now()), len(runnerIds))
+
+	if responseErr != nil {
+		return nil, nil, responseErr
+	}
+
+	return recomputation, runnerIds, nil
+}
+
+// recompute rebuilds the bests runner by runner, each in a transaction of
+// its own, so writes to other runners go on in the meantime.
+func (bs BestsService) recompute(ctx context.Context, recomputation *models.BestsRecomputation, runnerIds []string) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "BestsService.recompute")
+	defer span.End()
+
+	for _, runnerId := range runnerIds {
+		if ctx.Err() != nil {
+			recomputation.Status = models.BESTS_RECOMPUTATION_FAILED
+			recomputation.Error = BESTS_RECOMPUTATION_INTERRUPTED
+			bs.saveRecomputation(ctx, recomputation)
+
+			return &models.ResponseError{
+				Message: BESTS_RECOMPUTATION_INTERRUPTED,
+				Status:  http.StatusServiceUnavailable,
+				Code:    models.ERROR_CODE_INTERNAL,
+			}
+		}
+
+		var bests int64
+
+		responseErr := bs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+			runner, responseErr := repos.Runners.QueryGetRunnerForUpdate(ctx, runnerId)
+
+			// Runners purged in the meantime have no bests left
+			if responseErr != nil || runner == nil {
+				return responseErr
+			}
+
+			bests, responseErr = repos.Results.QueryRecomputeRunnerBests(ctx, runnerId, bs.season)
+
+			if responseErr != nil {
+				return responseErr
+			}
+
+			return repos.Runners.QueryIncrementRunnerVersion(ctx, runnerId)
+		})
+
+		if responseErr != nil {
+			recomputation.Status = models.BESTS_RECOMPUTATION_FAILED
+			recomputation.Error = responseErr.Message
+			bs.saveRecomputation(ctx, recomputation)
+
+			return responseErr
+		}
+
+		recomputation.Bests += bests
+		recomputation.RunnersDone++
+
+		if recomputation.RunnersDone%BESTS_RECOMPUTATION_PROGRESS_INTERVAL == 0 {
+			bs.saveRecomputation(ctx, recomputation)
+		}
+	}
+
+	recomputation.Status = models.BESTS_RECOMPUTATION_SUCCEEDED
+	bs.saveRecomputation(ctx, recomputation)
+
+	return nil
+}
+
+// saveRecomputation stores the progress of the recomputation, also once ctx
+// is done. Failing to do so is only logged, as the bests themselves are
+// updated.
+func (bs BestsService) saveRecomputation(ctx context.Context, recomputation *models.BestsRecomputation) {
+	ctx = context.WithoutCancel(ctx)
+
+	if recomputation.Status != models.BESTS_RECOMPUTATION_RUNNING {
+		finishedAt := time.Now()
+		recomputation.FinishedAt = &finishedAt
+	}
+
+	responseErr := bs.bestsRepository.QueryUpdateBestsRecomputation(ctx, recomputation)
+
+	if responseErr != nil {
+		logging.FromContext(ctx).Error("Failed to store progress of bests recomputation", "id", recomputation.ID, "error", responseErr.Message)
+	}
+}
+
+// RunSeasonResets rebuilds all bests whenever a new season starts, until ctx
+// is done. Season bests are read for the season of the current date, so they
+// start over at that moment; the rebuild regroups bests that were stored
+// with an earlier season start.
+func (bs BestsService) RunSeasonResets(ctx context.Context) {
+	for {
+		next := bs.season.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		bs.resetSeason(ctx, next)
+	}
+}
+
+// resetSeason rebuilds all bests for the season starting at start. Every
+// instance tries at the same moment, the one holding the lock does it and
+// the others skip it, also if it is done by the time they try.
+func (bs BestsService) resetSeason(ctx context.Context, start time.Time) {
+	unlock, responseErr := bs.bestsRepository.QueryTryLockBestsRecomputation(ctx)
+
+	if responseErr != nil {
+		slog.Error("Error while locking bests recomputation", "error", responseErr.Message)
+		return
+	}
+
+	if unlock == nil {
+		slog.Info("Bests are recomputed by another instance", "season", bs.season.Of(start))
+		return
+	}
+
+	defer unlock()
+
+	done, responseErr := bs.bestsRepository.QueryHasSucceededBestsRecomputationSince(ctx, start)
+
+	if responseErr != nil {
+		slog.Error("Error while recomputing bests", "error", responseErr.Message)
+		return
+	}
+
+	if done {
+		slog.Info("Bests were recomputed by another instance", "season", bs.season.Of(start))
+		return
+	}
+
+	slog.Info("New season started, recomputing bests", "season", bs.season.Of(start))
+	recomputation, runnerIds, responseErr := bs.createRecomputation(ctx)
+
+	if responseErr != nil {
+		slog.Error("Error while recomputing bests", "error", responseErr.Message)
+		return
+	}
+
+	responseErr = bs.recompute(ctx, recomputation, runnerIds)
+
+	if responseErr != nil {
+		slog.Error("Error while recomputing bests", "error", responseErr.Message)
+		return
+	}
+
+	slog.Info("Recomputed bests", "bests", recomputation.Bests)
+}
@@ -0,0 +1,491 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"runners/models"
+	"runners/repositories"
+	"runners/tracing"
+	"runners/validation"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventsService struct {
+	eventsRepository *repositories.EventsRepository
+	unitOfWork       *repositories.UnitOfWork
+	season           models.Season
+}
+
+func NewEventsService(eventsRepository *repositories.EventsRepository, unitOfWork *repositories.UnitOfWork, season models.Season) *EventsService {
+	return &EventsService{
+		eventsRepository: eventsRepository,
+		unitOfWork:       unitOfWork,
+		season:           season,
+	}
+}
+
+// CreateEvent creates the event together with the races given with it.
+func (es EventsService) CreateEvent(ctx context.Context, event *models.Event) (*models.Event, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.CreateEvent")
+	defer span.End()
+
+	for _, race := range event.Races {
+		applyRaceDefaults(race)
+	}
+
+	validator := validation.NewValidator()
+	validation.Event(validator, event)
+
+	for i, race := range event.Races {
+		validation.Race(validator.Nested("races["+strconv.Itoa(i)+"]."), race)
+	}
+
+	responseErr := validator.Error(models.ERROR_CODE_INVALID_EVENT)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	var createdEvent *models.Event
+
+	responseErr = es.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		var responseErr *models.ResponseError
+		createdEvent, responseErr = repos.Events.QueryCreateEvent(ctx, event)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		responseErr = recordAudit(ctx, repos, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_EVENT, createdEvent.ID, nil, createdEvent)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		for _, race := range event.Races {
+			race.EventID = createdEvent.ID
+			createdRace, responseErr := createRace(ctx, repos, race)
+
+			if responseErr != nil {
+				return responseErr
+			}
+
+			createdEvent.Races = append(createdEvent.Races, createdRace)
+		}
+
+		return nil
+	})
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return createdEvent, nil
+}
+
+// UpdateEvent updates the event and the location and year of its results.
+// Moving the event to another year moves the results to another season.
+func (es EventsService) UpdateEvent(ctx context.Context, event *models.Event) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.UpdateEvent")
+	defer span.End()
+
+	responseErr := validateEventId(event.ID)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	validator := validation.NewValidator()
+	validation.Event(validator, event)
+	responseErr = validator.Error(models.ERROR_CODE_INVALID_EVENT)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	now := time.Now()
+
+	return es.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Events.QueryGetEventForUpdate(ctx, event.ID)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if before == nil {
+			return eventNotFound()
+		}
+
+		runnerIds, responseErr := repos.Events.QueryGetEventRunnerIDs(ctx, event.ID)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if len(runnerIds) > 0 && event.Date > now.Format(models.DATE_FORMAT) {
+			return &models.ResponseError{
+				Message: "Event with results can not be moved into the future",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_EVENT,
+				Fields:  []*models.FieldError{{Field: "date", Message: "Event with results can not be moved into the future"}},
+			}
+		}
+
+		responseErr = repos.Events.QueryUpdateEvent(ctx, event)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		eventDate, _ := time.Parse(models.DATE_FORMAT, event.Date)
+		responseErr = repos.Events.QueryUpdateEventResults(ctx, event, eventDate.Year())
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		beforeDate, _ := time.Parse(models.DATE_FORMAT, before.Date)
+
+		// Results show the city and year of the event, so the runners change
+		// with every update. Their bests only change with the season.
+		seasonChanged := es.season.Of(eventDate) != es.season.Of(beforeDate)
+
+		for _, runnerId := range runnerIds {
+			if seasonChanged {
+				responseErr = updateRunnersBests(ctx, repos, runnerId, es.season)
+			} else {
+				responseErr = repos.Runners.QueryIncrementRunnerVersion(ctx, runnerId)
+			}
+
+			if responseErr != nil {
+				return responseErr
+			}
+		}
+
+		after := *event
+		after.Races = nil
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_EVENT, event.ID, before, &after)
+	})
+}
+
+// DeleteEvent deletes the event and its races. Events with results can not
+// be deleted.
+func (es EventsService) DeleteEvent(ctx context.Context, eventId string) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.DeleteEvent")
+	defer span.End()
+
+	responseErr := validateEventId(eventId)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	return es.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Events.QueryGetEventForUpdate(ctx, eventId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if before == nil {
+			return eventNotFound()
+		}
+
+		responseErr = repos.Events.QueryDeleteEvent(ctx, eventId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_EVENT, eventId, before, nil)
+	})
+}
+
+// GetEvent returns the event with its races, or nil if there is no such event.
+func (es EventsService) GetEvent(ctx context.Context, eventId string) (*models.Event, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.GetEvent")
+	defer span.End()
+
+	responseErr := validateEventId(eventId)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	event, responseErr := es.eventsRepository.QueryGetEvent(ctx, eventId)
+
+	if responseErr != nil || event == nil {
+		return nil, responseErr
+	}
+
+	event.Races, responseErr = es.eventsRepository.QueryGetRaces(ctx, eventId)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return event, nil
+}
+
+func (es EventsService) GetEvents(ctx context.Context, params *models.EventsParams) ([]*models.Event, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.GetEvents")
+	defer span.End()
+
+	filter := &repositories.EventsFilter{
+		Country: strings.TrimSpace(params.Country),
+	}
+
+	if params.Year != "" {
+		year, err := strconv.Atoi(params.Year)
+
+		if err != nil || year <= 0 {
+			return nil, &models.ResponseError{
+				Message: "Invalid year",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "year", Message: "Invalid year"}},
+			}
+		}
+
+		filter.Year = year
+	}
+
+	return es.eventsRepository.QueryGetEvents(ctx, filter)
+}
+
+func (es EventsService) CreateRace(ctx context.Context, race *models.Race) (*models.Race, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.CreateRace")
+	defer span.End()
+
+	responseErr := validateEventId(race.EventID)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	applyRaceDefaults(race)
+	responseErr = validateRace(race)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	var createdRace *models.Race
+
+	responseErr = es.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		event, responseErr := repos.Events.QueryGetEventForUpdate(ctx, race.EventID)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if event == nil {
+			return eventNotFound()
+		}
+
+		createdRace, responseErr = createRace(ctx, repos, race)
+
+		return responseErr
+	})
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return createdRace, nil
+}
+
+func (es EventsService) UpdateRace(ctx context.Context, race *models.Race) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.UpdateRace")
+	defer span.End()
+
+	responseErr := validateRaceIds(race.EventID, race.ID)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	applyRaceDefaults(race)
+	responseErr = validateRace(race)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	return es.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Events.QueryGetRaceForUpdate(ctx, race.ID)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if before == nil || before.EventID != race.EventID {
+			return raceNotFound()
+		}
+
+		responseErr = repos.Events.QueryUpdateRace(ctx, race)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if race.DistanceMeters != before.DistanceMeters {
+			runnerIds, responseErr := repos.Events.QueryUpdateRaceResults(ctx, race)
+
+			if responseErr != nil {
+				return responseErr
+			}
+
+			for _, runnerId := range runnerIds {
+				responseErr = updateRunnersBests(ctx, repos, runnerId, es.season)
+
+				if responseErr != nil {
+					return responseErr
+				}
+			}
+		}
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_RACE, race.ID, before, race)
+	})
+}
+
+// DeleteRace deletes a race of the event. Races with results can not be
+// deleted.
+func (es EventsService) DeleteRace(ctx context.Context, eventId string, raceId string) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.DeleteRace")
+	defer span.End()
+
+	responseErr := validateRaceIds(eventId, raceId)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	return es.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Events.QueryGetRaceForUpdate(ctx, raceId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if before == nil || before.EventID != eventId {
+			return raceNotFound()
+		}
+
+		responseErr = repos.Events.QueryDeleteRace(ctx, raceId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_RACE, raceId, before, nil)
+	})
+}
+
+// GetRaces returns the races of the event, or nil if there is no such event.
+func (es EventsService) GetRaces(ctx context.Context, eventId string) ([]*models.Race, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "EventsService.GetRaces")
+	defer span.End()
+
+	responseErr := validateEventId(eventId)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	event, responseErr := es.eventsRepository.QueryGetEvent(ctx, eventId)
+
+	if responseErr != nil || event == nil {
+		return nil, responseErr
+	}
+
+	return es.eventsRepository.QueryGetRaces(ctx, eventId)
+}
+
+func createRace(ctx context.Context, repos *repositories.Repositories, race *models.Race) (*models.Race, *models.ResponseError) {
+	createdRace, responseErr := repos.Events.QueryCreateRace(ctx, race)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	responseErr = recordAudit(ctx, repos, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_RACE, createdRace.ID, nil, createdRace)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return createdRace, nil
+}
+
+func applyRaceDefaults(race *models.Race) {
+	if race.Surface == "" {
+		race.Surface = models.SURFACE_ROAD
+	}
+
+	if race.Certification == "" {
+		race.Certification = models.CERTIFICATION_NONE
+	}
+}
+
+func validateRace(race *models.Race) *models.ResponseError {
+	validator := validation.NewValidator()
+	validation.Race(validator, race)
+
+	return validator.Error(models.ERROR_CODE_INVALID_RACE)
+}
+
+func validateEventId(eventId string) *models.ResponseError {
+	err := uuid.Validate(eventId)
+
+	if err != nil {
+		return &models.ResponseError{
+			Message: "Invalid event ID",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_EVENT_ID,
+		}
+	}
+
+	return nil
+}
+
+func validateRaceIds(eventId string, raceId string) *models.ResponseError {
+	responseErr := validateEventId(eventId)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	err := uuid.Validate(raceId)
+
+	if err != nil {
+		return &models.ResponseError{
+			Message: "Invalid race ID",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_RACE_ID,
+		}
+	}
+
+	return nil
+}
+
+func eventNotFound() *models.ResponseError {
+	return &models.ResponseError{
+		Message: "Event not found",
+		Status:  http.StatusNotFound,
+		Code:    models.ERROR_CODE_EVENT_NOT_FOUND,
+	}
+}
+
+func raceNotFound() *models.ResponseError {
+	return &models.ResponseError{
+		Message: "Race not found",
+		Status:  http.StatusNotFound,
+		Code:    models.ERROR_CODE_RACE_NOT_FOUND,
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runners/logging"
+	"runners/models"
+	"runners/repositories"
+	"time"
+)
+
+const IDEMPOTENCY_CLEANUP_INTERVAL = time.Hour
+
+type IdempotencyService struct {
+	idempotencyRepository *repositories.IdempotencyRepository
+	window                time.Duration
+}
+
+func NewIdempotencyService(idempotencyRepository *repositories.IdempotencyRepository, window time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		idempotencyRepository: idempotencyRepository,
+		window:                window,
+	}
+}
+
+// RunCleanup deletes the keys that are older than the window every hour
+// until ctx is done.
+func (is IdempotencyService) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(IDEMPOTENCY_CLEANUP_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, responseErr := is.idempotencyRepository.QueryDeleteExpiredIdempotencyKeys(ctx, time.Now().Add(-is.window))
+
+		if responseErr != nil {
+			slog.Error("Error while deleting expired idempotency keys", "error", responseErr.Message)
+			continue
+		}
+
+		slog.Debug("Deleted expired idempotency keys", "keys", deleted)
+	}
+}
+
+// idempotent runs work inside the transaction of repos and stores response
+// with its status for key once work succeeded. If the request was answered
+// within window, work does not run and the stored response is decoded into
+// response instead. Requests without key just run work.
+func idempotent(ctx context.Context, repos *repositories.Repositories, key *models.IdempotencyKey, window time.Duration, status int, response any, work func() *models.ResponseError) *models.ResponseError {
+	if key == nil {
+		return work()
+	}
+
+	responseErr := repos.Idempotency.QueryLockIdempotencyKey(ctx, key)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	stored, responseErr := repos.Idempotency.QueryGetIdempotentResponse(ctx, key, time.Now().Add(-window))
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	if stored != nil {
+		if stored.RequestHash != key.RequestHash {
+			return &models.ResponseError{
+				Message: "Idempotency-Key was already used for a different request",
+				Status:  http.StatusUnprocessableEntity,
+				Code:    models.ERROR_CODE_IDEMPOTENCY_KEY_REUSED,
+			}
+		}
+
+		err := json.Unmarshal(stored.Body, response)
+
+		if err != nil {
+			return idempotencyError(ctx, "Failed to decode stored response", err)
+		}
+
+		key.Replayed = true
+		key.Status = stored.Status
+
+		return nil
+	}
+
+	responseErr = work()
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	body, err := json.Marshal(response)
+
+	if err != nil {
+		return idempotencyError(ctx, "Failed to encode response", err)
+	}
+
+	key.Status = status
+
+	return repos.Idempotency.QuerySaveIdempotentResponse(ctx, key, status, body)
+}
+
+func idempotencyError(ctx context.Context, message string, err error) *models.ResponseError {
+	logging.FromContext(ctx).Error(message, "error", err)
+
+	return &models.ResponseError{
+		Message: message,
+		Status:  http.StatusInternalServerError,
+		Code:    models.ERROR_CODE_INTERNAL,
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// Jobs runs the background work of the app with its own context, so shutdown
+// can cancel the work and wait for it before the database is closed.
+type Jobs struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func NewJobs(ctx context.Context) *Jobs {
+	return &Jobs{
+		ctx: ctx,
+	}
+}
+
+// Go runs work in the background. The context of work is done when the jobs
+// are cancelled and carries the values of ctx, e.g. its logger.
+func (j *Jobs) Go(ctx context.Context, work func(ctx context.Context)) {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(j.ctx, cancel)
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		defer stop()
+		defer cancel()
+
+		work(jobCtx)
+	}()
+}
+
+// Wait blocks until all work started with Go returned.
+func (j *Jobs) Wait() {
+	j.wg.Wait()
+}
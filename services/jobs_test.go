@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobsWaitForCancelledWork(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs := NewJobs(ctx)
+	requestCtx, cancelRequest := context.WithCancel(context.Background())
+	stopped := false
+
+	jobs.Go(requestCtx, func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		stopped = true
+	})
+
+	// Work outlives the request it was started for
+	cancelRequest()
+	cancel()
+	jobs.Wait()
+
+	assert.True(t, stopped)
+}
@@ -1,327 +1,334 @@
 package services
 
 import (
+	"context"
 	"net/http"
 	"runners/interfaces"
 	"runners/models"
 	"runners/repositories"
+	"runners/tracing"
+	"runners/validation"
+	"slices"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type ResultsService struct {
-	resultsRepository *repositories.ResultsRepository
-	runnersRepository *repositories.RunnersRepository
+	unitOfWork        *repositories.UnitOfWork
+	season            models.Season
+	idempotencyWindow time.Duration
 }
 
-func NewResultsService(resultsRepository *repositories.ResultsRepository, runnersRepository *repositories.RunnersRepository) interfaces.ResultsServiceInterface {
+func NewResultsService(unitOfWork *repositories.UnitOfWork, season models.Season, idempotencyWindow time.Duration) interfaces.ResultsServiceInterface {
 	return &ResultsService{
-		resultsRepository: resultsRepository,
-		runnersRepository: runnersRepository,
+		unitOfWork:        unitOfWork,
+		season:            season,
+		idempotencyWindow: idempotencyWindow,
 	}
 }
 
-func (rs ResultsService) CreateResult(result *models.Result) (*models.Result, *models.ResponseError) {
-	currentYear := time.Now().Year()
+// CreateResult records the result. A result recorded for idempotencyKey
+// before is returned instead of recording it twice.
+func (rs ResultsService) CreateResult(ctx context.Context, result *models.Result, idempotencyKey *models.IdempotencyKey) (*models.Result, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "ResultsService.CreateResult")
+	defer span.End()
 
-	responseErr := validateInput(result, currentYear)
+	applyResultDefaults(result)
+	responseErr := validateInput(result)
 
 	if responseErr != nil {
 		return nil, responseErr
 	}
 
-	raceResult, err := parseRaceResult(result.RaceResult)
+	var createdResult *models.Result
 
-	if err != nil {
-		return nil, &models.ResponseError{
-			Message: "Invalid race result",
-			Status:  http.StatusBadRequest,
-		}
-	}
+	responseErr = rs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		return idempotent(ctx, repos, idempotencyKey, rs.idempotencyWindow, http.StatusOK, &createdResult, func() *models.ResponseError {
+			responseErr := applyRace(ctx, repos, result, time.Now())
 
-	err = repositories.BeginTransaction(rs.runnersRepository, rs.resultsRepository)
+			if responseErr != nil {
+				return responseErr
+			}
 
-	if err != nil {
-		return nil, &models.ResponseError{
-			Message: "Failed to start transaction",
-			Status:  http.StatusBadRequest,
-		}
-	}
+			// Lock the runner before the insert takes a key share lock on
+			// it, so concurrent results for the runner can not deadlock
+			runner, responseErr := repos.Runners.QueryGetRunnerForUpdate(ctx, result.RunnerID)
 
-	createdResult, responseErr := rs.resultsRepository.QueryCreateResult(result)
+			if responseErr != nil {
+				return responseErr
+			}
 
-	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
-		return nil, responseErr
-	}
+			if runner == nil {
+				return runnerNotFound()
+			}
 
-	responseErr = rs.updateRunnersResult(result, raceResult, currentYear)
+			createdResult, responseErr = repos.Results.QueryCreateResult(ctx, result)
+
+			if responseErr != nil {
+				return responseErr
+			}
+
+			responseErr = updateRunnersBests(ctx, repos, result.RunnerID, rs.season)
+
+			if responseErr != nil {
+				return responseErr
+			}
+
+			return recordAudit(ctx, repos, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_RESULT, createdResult.ID, nil, createdResult)
+		})
+	})
 
 	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
 		return nil, responseErr
 	}
 
-	repositories.CommitTransaction(rs.runnersRepository, rs.resultsRepository)
-
 	return createdResult, nil
 }
 
-func (rs ResultsService) UpdateResult(result *models.Result) *models.ResponseError {
-	currentYear := time.Now().Year()
+// UpdateResult replaces the result with the given id if result.Version is
+// still its current version and returns it with the new version. The bests
+// of its runner are recomputed if the change can affect them.
+func (rs ResultsService) UpdateResult(ctx context.Context, result *models.Result) (*models.Result, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "ResultsService.UpdateResult")
+	defer span.End()
 
-	responseErr := validateInput(result, currentYear)
+	responseErr := validateResultId(result.ID)
 
 	if responseErr != nil {
-		return responseErr
+		return nil, responseErr
 	}
 
-	raceResult, err := parseRaceResult(result.RaceResult)
+	applyResultDefaults(result)
+	responseErr = validateInput(result)
 
-	if err != nil {
-		return &models.ResponseError{
-			Message: "Invalid race result",
-			Status:  http.StatusBadRequest,
-		}
+	if responseErr != nil {
+		return nil, responseErr
 	}
 
-	err = repositories.BeginTransaction(rs.runnersRepository, rs.resultsRepository)
+	responseErr = rs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Results.QueryGetResultForUpdate(ctx, result.ID)
 
-	if err != nil {
-		return &models.ResponseError{
-			Message: "Failed to start transaction",
-			Status:  http.StatusBadRequest,
+		if responseErr != nil {
+			return responseErr
 		}
-	}
 
-	responseErr = rs.UpdateResult(result)
+		responseErr = checkVersion(result.Version, before.Version)
 
-	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
-		return responseErr
-	}
+		if responseErr != nil {
+			return responseErr
+		}
 
-	responseErr = rs.updateRunnersResult(result, raceResult, currentYear)
+		responseErr = applyRace(ctx, repos, result, time.Now())
 
-	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
-		return responseErr
-	}
+		if responseErr != nil {
+			return responseErr
+		}
 
-	repositories.CommitTransaction(rs.runnersRepository, rs.resultsRepository)
+		// Lock the runners in a fixed order, so moving results between two
+		// runners concurrently can not deadlock
+		runnerIds := []string{before.RunnerID}
 
-	return nil
-}
+		if result.RunnerID != before.RunnerID {
+			runnerIds = append(runnerIds, result.RunnerID)
+			slices.Sort(runnerIds)
+		}
 
-func (rs ResultsService) DeleteResult(resultId string) *models.ResponseError {
-	if resultId == "" {
-		return &models.ResponseError{
-			Message: "Invalid result ID",
-			Status:  http.StatusBadRequest,
+		for _, runnerId := range runnerIds {
+			runner, responseErr := repos.Runners.QueryGetRunnerForUpdate(ctx, runnerId)
+
+			if responseErr != nil {
+				return responseErr
+			}
+
+			if runner == nil {
+				return runnerNotFound()
+			}
 		}
-	}
 
-	err := repositories.BeginTransaction(rs.runnersRepository, rs.resultsRepository)
+		responseErr = repos.Results.QueryUpdateResult(ctx, result)
 
-	if err != nil {
-		return &models.ResponseError{
-			Message: "Failed to start transaction",
-			Status:  http.StatusBadRequest,
+		if responseErr != nil {
+			return responseErr
 		}
-	}
 
-	result, responseErr := rs.resultsRepository.QueryDeleteResult(resultId)
+		result.Version = before.Version + 1
 
-	if responseErr != nil {
-		return responseErr
-	}
+		for _, runnerId := range runnerIds {
+			if affectsBests(before, result) {
+				_, responseErr = repos.Results.QueryRecomputeRunnerBests(ctx, runnerId, rs.season)
 
-	runner, responseErr := rs.runnersRepository.QueryGetRunner(result.RunnerID)
+				if responseErr != nil {
+					return responseErr
+				}
+			}
 
-	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
-		return responseErr
-	}
+			responseErr = repos.Runners.QueryIncrementRunnerVersion(ctx, runnerId)
 
-	if runner == nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
-		return &models.ResponseError{
-			Message: "Runner not found",
-			Status:  http.StatusNotFound,
+			if responseErr != nil {
+				return responseErr
+			}
 		}
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_RESULT, result.ID, before, result)
+	})
+
+	if responseErr != nil {
+		return nil, responseErr
 	}
 
-	runnersResults, responseErr := rs.resultsRepository.QueryGetAllRunnersResults(runner.ID)
+	return result, nil
+}
+
+// DeleteResult deletes the result if version is still its current version.
+func (rs ResultsService) DeleteResult(ctx context.Context, resultId string, version int) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "ResultsService.DeleteResult")
+	defer span.End()
+
+	responseErr := validateResultId(resultId)
 
 	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
 		return responseErr
 	}
 
-	runner.Results = runnersResults
+	return rs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Results.QueryGetResultForUpdate(ctx, resultId)
 
-	//Checking if the deleted result is personal best for the runner
-	if runner.PersonalBest == result.RaceResult {
-		personalBest, responseErr := rs.resultsRepository.QueryGetPersonalBestResults(result.RunnerID)
+		if responseErr != nil {
+			return responseErr
+		}
+
+		responseErr = checkVersion(version, before.Version)
 
 		if responseErr != nil {
-			repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
 			return responseErr
 		}
 
-		runner.PersonalBest = personalBest
-	}
+		result, responseErr := repos.Results.QueryDeleteResult(ctx, resultId)
 
-	//Checking if the deleted result is season best for the runner
-	currentYear := time.Now().Year()
+		if responseErr != nil {
+			return responseErr
+		}
 
-	if runner.SeasonBest == result.RaceResult && result.Year == currentYear {
-		seasonBest, responseErr := rs.resultsRepository.QueryGetSeasonBestResults(result.RunnerID, result.Year)
+		responseErr = updateRunnersBests(ctx, repos, result.RunnerID, rs.season)
 
 		if responseErr != nil {
-			repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
 			return responseErr
 		}
 
-		runner.SeasonBest = seasonBest
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_RESULT, resultId, result, nil)
+	})
+}
+
+func applyResultDefaults(result *models.Result) {
+	if result.Status == "" {
+		result.Status = models.RESULT_STATUS_FINISHED
 	}
+}
 
-	_, responseErr = rs.runnersRepository.QueryUpdateRunnerResult(runner)
+func validateInput(result *models.Result) *models.ResponseError {
+	validator := validation.NewValidator()
+	validation.Result(validator, result)
+
+	return validator.Error(models.ERROR_CODE_INVALID_RACE_RESULT)
+}
+
+// applyRace copies the distance of the race and the location and year of its
+// event to result. Results can only be recorded for events that have taken
+// place.
+func applyRace(ctx context.Context, repos *repositories.Repositories, result *models.Result, now time.Time) *models.ResponseError {
+	race, responseErr := repos.Events.QueryGetRace(ctx, result.RaceID)
 
 	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
 		return responseErr
 	}
 
-	repositories.CommitTransaction(rs.runnersRepository, rs.resultsRepository)
+	if race == nil {
+		return raceNotFound()
+	}
 
-	return nil
-}
+	event, responseErr := repos.Events.QueryGetEvent(ctx, race.EventID)
 
-func validateInput(result *models.Result, currentYear int) *models.ResponseError {
-	if result.RunnerID == "" {
-		return &models.ResponseError{
-			Message: "Invalid Runner ID",
-			Status:  http.StatusBadRequest,
-		}
+	if responseErr != nil {
+		return responseErr
 	}
 
-	if result.RaceResult == "" {
-		return &models.ResponseError{
-			Message: "Invalid race result",
-			Status:  http.StatusBadRequest,
-		}
+	if event == nil {
+		return raceNotFound()
 	}
 
-	if result.Location == "" {
-		return &models.ResponseError{
-			Message: "Invalid location",
-			Status:  http.StatusBadRequest,
-		}
-	}
+	eventDate, err := time.Parse(models.DATE_FORMAT, event.Date)
 
-	if result.Position < 0 {
+	if err != nil || event.Date > now.Format(models.DATE_FORMAT) {
 		return &models.ResponseError{
-			Message: "Invalid position",
+			Message: "Race has not taken place yet",
 			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_RACE_RESULT,
+			Fields:  []*models.FieldError{{Field: "race_id", Message: "Race has not taken place yet"}},
 		}
 	}
 
-	if result.Year < 0 || result.Year > currentYear {
-		return &models.ResponseError{
-			Message: "Invalid year",
-			Status:  http.StatusBadRequest,
-		}
-	}
+	result.DistanceMeters = race.DistanceMeters
+	result.Location = event.City
+	result.Year = eventDate.Year()
 
 	return nil
 }
 
-func parseRaceResult(timeString string) (time.Duration, error) {
-	return time.ParseDuration(timeString[0:2] + "h" + timeString[3:5] + "m" + timeString[6:8] + "s")
-}
-
-func (rs ResultsService) updateRunnersResult(result *models.Result, raceResult time.Duration, currentYear int) *models.ResponseError {
+// updateRunnersBests recomputes the bests of the runner from their results
+// and bumps the version of the runner. The runner row is locked first, so
+// concurrent result writes for the same runner recompute one after another.
+func updateRunnersBests(ctx context.Context, repos *repositories.Repositories, runnerId string, season models.Season) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "ResultsService.updateRunnersBests")
+	defer span.End()
 
-	runner, responseErr := rs.runnersRepository.QueryGetRunner(result.RunnerID)
+	runner, responseErr := repos.Runners.QueryGetRunnerForUpdate(ctx, runnerId)
 
 	if responseErr != nil {
 		return responseErr
 	}
 
 	if runner == nil {
-		return &models.ResponseError{
-			Message: "Runner not found",
-			Status:  http.StatusNotFound,
-		}
+		return runnerNotFound()
 	}
 
-	runnersResults, responseErr := rs.resultsRepository.QueryGetAllRunnersResults(runner.ID)
+	_, responseErr = repos.Results.QueryRecomputeRunnerBests(ctx, runnerId, season)
 
 	if responseErr != nil {
-		repositories.RollbackTransaction(rs.runnersRepository, rs.resultsRepository)
 		return responseErr
 	}
 
-	runner.Results = runnersResults
-
-	responseErr = updateRunnersPersonalBest(runner, result, raceResult)
-	if responseErr != nil {
-		return responseErr
-	}
-
-	if result.Year == currentYear {
-		responseErr = updateRunnersSeasonBest(runner, result, raceResult)
-		if responseErr != nil {
-			return responseErr
-		}
-	}
-
-	_, responseErr = rs.runnersRepository.QueryUpdateRunnerResult(runner)
-
-	if responseErr != nil {
-		return responseErr
-	}
-
-	return nil
+	return repos.Runners.QueryIncrementRunnerVersion(ctx, runnerId)
 }
 
-func updateRunnersPersonalBest(runner *models.Runner, result *models.Result, raceResult time.Duration) *models.ResponseError {
-	if runner.PersonalBest == "" {
-		runner.PersonalBest = result.RaceResult
-	} else {
-		personalBest, err := parseRaceResult(runner.PersonalBest)
-
-		if err != nil {
+// affectsBests reports if replacing before by after can change the bests of
+// a runner.
+func affectsBests(before *models.Result, after *models.Result) bool {
+	return before.RunnerID != after.RunnerID ||
+		before.Status != after.Status ||
+		before.RaceResult != after.RaceResult ||
+		before.RaceID != after.RaceID ||
+		before.DistanceMeters != after.DistanceMeters
+}
 
-			return &models.ResponseError{
-				Message: "Failed to parse personal best",
-				Status:  http.StatusInternalServerError,
-			}
-		}
+func validateResultId(resultId string) *models.ResponseError {
+	err := uuid.Validate(resultId)
 
-		if raceResult < personalBest {
-			runner.PersonalBest = result.RaceResult
+	if err != nil {
+		return &models.ResponseError{
+			Message: "Invalid result ID",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_RESULT_ID,
 		}
 	}
 
 	return nil
 }
 
-func updateRunnersSeasonBest(runner *models.Runner, result *models.Result, raceResult time.Duration) *models.ResponseError {
-	if runner.SeasonBest == "" {
-		runner.SeasonBest = result.RaceResult
-	} else {
-		seasonBest, err := parseRaceResult(runner.SeasonBest)
-		if err != nil {
-			return &models.ResponseError{
-				Message: "Failed to parse season best",
-				Status:  http.StatusInternalServerError,
-			}
-		}
-		if raceResult < seasonBest {
-			runner.SeasonBest = result.RaceResult
-		}
+func runnerNotFound() *models.ResponseError {
+	return &models.ResponseError{
+		Message: "Runner not found",
+		Status:  http.StatusNotFound,
+		Code:    models.ERROR_CODE_RUNNER_NOT_FOUND,
 	}
-
-	return nil
 }
@@ -0,0 +1,550 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"runners/models"
+	"runners/repositories"
+	"runners/testhelpers"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	_ "github.com/lib/pq"
+)
+
+type ResultsServiceTestSuite struct {
+	suite.Suite
+	pgContainer    *testhelpers.PostgresContainer
+	dbHandler      *sql.DB
+	resultsService *ResultsService
+	ctx            context.Context
+}
+
+func (suite *ResultsServiceTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer(suite.ctx)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	suite.pgContainer = pgContainer
+	dbHandler, err := sql.Open("postgres", suite.pgContainer.ConnectionString)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = dbHandler.Ping()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	suite.dbHandler = dbHandler
+	suite.resultsService = &ResultsService{
+		unitOfWork:        repositories.NewUnitOfWork(dbHandler),
+		season:            models.Season{StartMonth: time.January, StartDay: 1},
+		idempotencyWindow: time.Hour,
+	}
+}
+
+func (suite *ResultsServiceTestSuite) TearDownSuite() {
+	suite.dbHandler.Close()
+
+	err := suite.pgContainer.Terminate(suite.ctx)
+	if err != nil {
+		log.Fatalf("Error while terminating postgres container: %s", err)
+	}
+}
+
+func (suite *ResultsServiceTestSuite) TestCreateResultConcurrent() {
+	t := suite.T()
+
+	runnerId, raceId := suite.createRunnerAndRace()
+
+	resultsCount := 20
+
+	var wg sync.WaitGroup
+	errs := make(chan *models.ResponseError, resultsCount)
+
+	for i := 0; i < resultsCount; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			_, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+				RunnerID:   runnerId,
+				RaceID:     raceId,
+				RaceResult: models.RaceTime(2*time.Hour + time.Duration(10+i)*time.Minute),
+				Position:   i + 1,
+			}, nil)
+
+			if responseErr != nil {
+				errs <- responseErr
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for responseErr := range errs {
+		t.Errorf("Unexpected error: %s", responseErr.Message)
+	}
+
+	var count int
+	err := suite.dbHandler.QueryRow("SELECT COUNT(*) FROM results WHERE runner_id = $1", runnerId).Scan(&count)
+
+	require.NoError(t, err)
+	assert.Equal(t, resultsCount, count)
+
+	var distanceMeters, season int
+	var best string
+	err = suite.dbHandler.QueryRow("SELECT distance_meters, season, race_result FROM runner_bests WHERE runner_id = $1", runnerId).Scan(&distanceMeters, &season, &best)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.DISTANCE_MARATHON, distanceMeters)
+	assert.Equal(t, time.Now().Year(), season)
+	assert.Equal(t, "02:10:00", best)
+}
+
+func (suite *ResultsServiceTestSuite) TestCreateResultIdempotentConcurrent() {
+	t := suite.T()
+
+	runnerId, raceId := suite.createRunnerAndRace()
+
+	var userId string
+	err := suite.dbHandler.QueryRow("SELECT id FROM users WHERE username = 'admin'").Scan(&userId)
+
+	require.NoError(t, err)
+
+	retries := 10
+
+	var wg sync.WaitGroup
+	results := make(chan *models.Result, retries)
+	replayed := make(chan bool, retries)
+
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			key := &models.IdempotencyKey{
+				UserID:      userId,
+				Route:       models.IDEMPOTENCY_ROUTE_CREATE_RESULT,
+				Key:         "retried-result",
+				RequestHash: "hash",
+			}
+			result, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+				RunnerID:   runnerId,
+				RaceID:     raceId,
+				RaceResult: models.RaceTime(2*time.Hour + 10*time.Minute),
+				Position:   1,
+			}, key)
+
+			if responseErr != nil {
+				t.Errorf("Unexpected error: %s", responseErr.Message)
+				return
+			}
+
+			results <- result
+			replayed <- key.Replayed
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+	close(replayed)
+
+	resultIds := make(map[string]bool)
+	for result := range results {
+		resultIds[result.ID] = true
+	}
+
+	replays := 0
+	for isReplayed := range replayed {
+		if isReplayed {
+			replays++
+		}
+	}
+
+	assert.Len(t, resultIds, 1)
+	assert.Equal(t, retries-1, replays)
+
+	var count int
+	err = suite.dbHandler.QueryRow("SELECT COUNT(*) FROM results WHERE runner_id = $1", runnerId).Scan(&count)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+		RunnerID:   runnerId,
+		RaceID:     raceId,
+		RaceResult: models.RaceTime(2*time.Hour + 20*time.Minute),
+		Position:   2,
+	}, &models.IdempotencyKey{
+		UserID:      userId,
+		Route:       models.IDEMPOTENCY_ROUTE_CREATE_RESULT,
+		Key:         "retried-result",
+		RequestHash: "other hash",
+	})
+
+	require.NotNil(t, responseErr)
+	assert.Equal(t, models.ERROR_CODE_IDEMPOTENCY_KEY_REUSED, responseErr.Code)
+}
+
+func (suite *ResultsServiceTestSuite) TestUpdateEventBumpsRunnerVersion() {
+	t := suite.T()
+
+	runnerId, raceId := suite.createRunnerAndRace()
+
+	_, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+		RunnerID:   runnerId,
+		RaceID:     raceId,
+		RaceResult: models.RaceTime(2*time.Hour + 10*time.Minute),
+		Position:   1,
+	}, nil)
+
+	require.Nil(t, responseErr)
+
+	eventsService := NewEventsService(repositories.NewEventsRepository(suite.dbHandler), suite.resultsService.unitOfWork, suite.resultsService.season)
+	var eventId string
+	err := suite.dbHandler.QueryRow("SELECT event_id FROM races WHERE id = $1", raceId).Scan(&eventId)
+
+	require.NoError(t, err)
+
+	event, responseErr := eventsService.GetEvent(suite.ctx, eventId)
+
+	require.Nil(t, responseErr)
+
+	versionBefore := suite.queryRunnerVersion(runnerId)
+	event.Name = "BMW Berlin Marathon"
+	event.Races = nil
+	responseErr = eventsService.UpdateEvent(suite.ctx, event)
+
+	require.Nil(t, responseErr)
+	assert.Equal(t, versionBefore+1, suite.queryRunnerVersion(runnerId))
+	assert.Equal(t, "02:10:00", suite.queryBest(runnerId))
+}
+
+func (suite *ResultsServiceTestSuite) queryRunnerVersion(runnerId string) int {
+	var version int
+	err := suite.dbHandler.QueryRow("SELECT version FROM runners WHERE id = $1", runnerId).Scan(&version)
+
+	require.NoError(suite.T(), err)
+
+	return version
+}
+
+// createRunnerAndRace creates a runner and a marathon that took place today.
+func (suite *ResultsServiceTestSuite) createRunnerAndRace() (string, string) {
+	t := suite.T()
+
+	var runnerId string
+	err := suite.dbHandler.QueryRow(`
+		INSERT INTO
+			runners(first_name, last_name, age, country)
+		VALUES
+			('Eliud', 'Kipchoge', 39, 'Kenya')
+		RETURNING
+			id`).Scan(&runnerId)
+
+	require.NoError(t, err)
+
+	var raceId string
+	err = suite.dbHandler.QueryRow(`
+		WITH event AS (
+			INSERT INTO
+				events(name, event_date, city, country)
+			VALUES
+				('Berlin Marathon', CURRENT_DATE, 'Berlin', 'Germany')
+			RETURNING
+				id
+		)
+		INSERT INTO
+			races(event_id, name, distance_meters)
+		SELECT
+			id, 'Marathon', 42195
+		FROM
+			event
+		RETURNING
+			id`).Scan(&raceId)
+
+	require.NoError(t, err)
+
+	return runnerId, raceId
+}
+
+func (suite *ResultsServiceTestSuite) TestCreateResultNotFinished() {
+	t := suite.T()
+
+	runnerId, raceId := suite.createRunnerAndRace()
+
+	_, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+		RunnerID: runnerId,
+		RaceID:   raceId,
+		Status:   models.RESULT_STATUS_DNF,
+	}, nil)
+
+	require.Nil(t, responseErr)
+
+	_, responseErr = suite.resultsService.CreateResult(suite.ctx, &models.Result{
+		RunnerID:   runnerId,
+		RaceID:     raceId,
+		Status:     models.RESULT_STATUS_DQ,
+		Reason:     "Course cutting",
+		RaceResult: models.RaceTime(2 * time.Hour),
+	}, nil)
+
+	require.Nil(t, responseErr)
+
+	var count int
+	err := suite.dbHandler.QueryRow("SELECT COUNT(*) FROM runner_bests WHERE runner_id = $1", runnerId).Scan(&count)
+
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func (suite *ResultsServiceTestSuite) TestUpdateResultRecomputesBests() {
+	t := suite.T()
+
+	runnerId, raceId := suite.createRunnerAndRace()
+
+	first, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+		RunnerID:   runnerId,
+		RaceID:     raceId,
+		RaceResult: models.RaceTime(2*time.Hour + 10*time.Minute),
+		Position:   1,
+	}, nil)
+
+	require.Nil(t, responseErr)
+
+	second, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+		RunnerID:   runnerId,
+		RaceID:     raceId,
+		RaceResult: models.RaceTime(2*time.Hour + 20*time.Minute),
+		Position:   2,
+	}, nil)
+
+	require.Nil(t, responseErr)
+	assert.Equal(t, "02:10:00", suite.queryBest(runnerId))
+
+	// Worsening the best time falls back to the next best result
+	first.RaceResult = models.RaceTime(2*time.Hour + 30*time.Minute)
+	updated, responseErr := suite.resultsService.UpdateResult(suite.ctx, first)
+
+	require.Nil(t, responseErr)
+	assert.Equal(t, first.ID, updated.ID)
+	assert.Equal(t, "Berlin", updated.Location)
+	assert.Equal(t, "02:20:00", suite.queryBest(runnerId))
+
+	second.RaceResult = models.RaceTime(2*time.Hour + 5*time.Minute)
+	_, responseErr = suite.resultsService.UpdateResult(suite.ctx, second)
+
+	require.Nil(t, responseErr)
+	assert.Equal(t, "02:05:00", suite.queryBest(runnerId))
+
+	second.Status = models.RESULT_STATUS_DNF
+	second.RaceResult = 0
+	second.Position = 0
+	_, responseErr = suite.resultsService.UpdateResult(suite.ctx, second)
+
+	require.Nil(t, responseErr)
+	assert.Equal(t, "02:30:00", suite.queryBest(runnerId))
+
+	var raceResult sql.NullString
+	var status string
+	err := suite.dbHandler.QueryRow("SELECT race_result, status FROM results WHERE id = $1", second.ID).Scan(&raceResult, &status)
+
+	require.NoError(t, err)
+	assert.False(t, raceResult.Valid)
+	assert.Equal(t, models.RESULT_STATUS_DNF, status)
+}
+
+func (suite *ResultsServiceTestSuite) TestUpdateResultNotFound() {
+	t := suite.T()
+
+	runnerId, raceId := suite.createRunnerAndRace()
+
+	_, responseErr := suite.resultsService.UpdateResult(suite.ctx, &models.Result{
+		ID:         "e5280c8b-093d-457a-a535-2127326cd1b2",
+		RunnerID:   runnerId,
+		RaceID:     raceId,
+		RaceResult: models.RaceTime(2 * time.Hour),
+		Position:   1,
+	})
+
+	require.NotNil(t, responseErr)
+	assert.Equal(t, models.ERROR_CODE_RESULT_NOT_FOUND, responseErr.Code)
+}
+
+func (suite *ResultsServiceTestSuite) TestRecomputeAllBests() {
+	t := suite.T()
+
+	runnerId, raceId := suite.createRunnerAndRace()
+
+	_, responseErr := suite.resultsService.CreateResult(suite.ctx, &models.Result{
+		RunnerID:   runnerId,
+		RaceID:     raceId,
+		RaceResult: models.RaceTime(2*time.Hour + 10*time.Minute),
+		Position:   1,
+	}, nil)
+
+	require.Nil(t, responseErr)
+
+	_, err := suite.dbHandler.Exec(`
+		UPDATE
+			events
+		SET
+			event_date = '2023-03-01'
+		WHERE
+			id = (SELECT event_id FROM races WHERE id = $1)`, raceId)
+
+	require.NoError(t, err)
+
+	_, err = suite.dbHandler.Exec("DELETE FROM runner_bests WHERE runner_id = $1", runnerId)
+
+	require.NoError(t, err)
+
+	// Seasons starting on April 1 put a race in March into the previous season
+	bestsService := NewBestsService(
+		repositories.NewBestsRepository(suite.dbHandler),
+		repositories.NewRunnersRepository(suite.dbHandler),
+		suite.resultsService.unitOfWork,
+		models.Season{StartMonth: time.April, StartDay: 1},
+		nil)
+	recomputation, responseErr := bestsService.RecomputeAllBests(suite.ctx)
+
+	require.Nil(t, responseErr)
+	assert.Positive(t, recomputation.Bests)
+
+	stored, responseErr := bestsService.GetBestsRecomputation(suite.ctx, recomputation.ID)
+
+	require.Nil(t, responseErr)
+	assert.Equal(t, models.BESTS_RECOMPUTATION_SUCCEEDED, stored.Status)
+	assert.Equal(t, stored.Runners, stored.RunnersDone)
+	assert.NotNil(t, stored.FinishedAt)
+
+	var season int
+	var best string
+	err = suite.dbHandler.QueryRow("SELECT season, race_result FROM runner_bests WHERE runner_id = $1", runnerId).Scan(&season, &best)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2022, season)
+	assert.Equal(t, "02:10:00", best)
+}
+
+func (suite *ResultsServiceTestSuite) TestRecomputeAllBestsRunningConflict() {
+	t := suite.T()
+
+	var recomputationId string
+	err := suite.dbHandler.QueryRow(`
+		INSERT INTO
+			bests_recomputations(season, runners)
+		VALUES
+			(2024, 1)
+		RETURNING
+			id`).Scan(&recomputationId)
+
+	require.NoError(t, err)
+
+	bestsService := NewBestsService(
+		repositories.NewBestsRepository(suite.dbHandler),
+		repositories.NewRunnersRepository(suite.dbHandler),
+		suite.resultsService.unitOfWork,
+		suite.resultsService.season,
+		NewJobs(suite.ctx))
+	_, responseErr := bestsService.StartRecomputeAllBests(suite.ctx)
+
+	require.NotNil(t, responseErr)
+	assert.Equal(t, http.StatusConflict, responseErr.Status)
+	assert.Equal(t, models.ERROR_CODE_RECOMPUTATION_RUNNING, responseErr.Code)
+
+	// An instance that starts fails what a stopped instance left running
+	responseErr = bestsService.FailUnfinishedRecomputations(suite.ctx)
+
+	require.Nil(t, responseErr)
+
+	recomputation, responseErr := bestsService.GetBestsRecomputation(suite.ctx, recomputationId)
+
+	require.Nil(t, responseErr)
+	assert.Equal(t, models.BESTS_RECOMPUTATION_FAILED, recomputation.Status)
+	assert.NotNil(t, recomputation.FinishedAt)
+}
+
+func (suite *ResultsServiceTestSuite) TestResetSeasonOnce() {
+	t := suite.T()
+
+	bestsRepository := repositories.NewBestsRepository(suite.dbHandler)
+	bestsService := NewBestsService(
+		bestsRepository,
+		repositories.NewRunnersRepository(suite.dbHandler),
+		suite.resultsService.unitOfWork,
+		suite.resultsService.season,
+		nil)
+	countRecomputations := func() int {
+		var count int
+		err := suite.dbHandler.QueryRow("SELECT COUNT(*) FROM bests_recomputations").Scan(&count)
+
+		require.NoError(t, err)
+
+		return count
+	}
+	count := countRecomputations()
+
+	// Another instance holds the lock
+	unlock, responseErr := bestsRepository.QueryTryLockBestsRecomputation(suite.ctx)
+
+	require.Nil(t, responseErr)
+	require.NotNil(t, unlock)
+
+	bestsService.resetSeason(suite.ctx, time.Now())
+
+	assert.Equal(t, count, countRecomputations())
+
+	unlock()
+
+	start := time.Now()
+	bestsService.resetSeason(suite.ctx, start)
+
+	assert.Equal(t, count+1, countRecomputations())
+
+	// Instances that come later find the season done
+	bestsService.resetSeason(suite.ctx, start)
+
+	assert.Equal(t, count+1, countRecomputations())
+}
+
+// queryBest returns the marathon best of the runner in the current season.
+func (suite *ResultsServiceTestSuite) queryBest(runnerId string) string {
+	var best string
+	err := suite.dbHandler.QueryRow(`
+		SELECT
+			race_result
+		FROM
+			runner_bests
+		WHERE
+			runner_id = $1
+			AND
+			distance_meters = $2
+			AND
+			season = $3`, runnerId, models.DISTANCE_MARATHON, time.Now().Year()).Scan(&best)
+
+	require.NoError(suite.T(), err)
+
+	return best
+}
+
+func TestResultsServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(ResultsServiceTestSuite))
+}
@@ -1,10 +1,14 @@
 package services
 
 import (
-	"fmt"
+	"context"
+	"database/sql"
 	"net/http"
+	"runners/logging"
 	"runners/models"
 	"runners/repositories"
+	"runners/tracing"
+	"runners/validation"
 	"strconv"
 	"strings"
 	"time"
@@ -12,51 +16,72 @@ import (
 	"github.com/google/uuid"
 )
 
+const DEFAULT_BATCH_LIMIT = 10
+const MAX_BATCH_LIMIT = 100
+
 type RunnersService struct {
 	runnersRepository *repositories.RunnersRepository
 	resultsRepository *repositories.ResultsRepository
+	unitOfWork        *repositories.UnitOfWork
+	season            models.Season
+	idempotencyWindow time.Duration
 }
 
 func NewRunnersService(
 	runnersRepository *repositories.RunnersRepository,
-	resultsRepository *repositories.ResultsRepository) *RunnersService {
+	resultsRepository *repositories.ResultsRepository,
+	unitOfWork *repositories.UnitOfWork,
+	season models.Season,
+	idempotencyWindow time.Duration) *RunnersService {
 	return &RunnersService{
 		runnersRepository: runnersRepository,
 		resultsRepository: resultsRepository,
+		unitOfWork:        unitOfWork,
+		season:            season,
+		idempotencyWindow: idempotencyWindow,
 	}
 }
 
-func (rs RunnersService) CreateRunner(runner *models.Runner) (*models.Runner, *models.ResponseError) {
+// CreateRunner creates the runner. A runner created for idempotencyKey
+// before is returned instead of creating another one.
+func (rs RunnersService) CreateRunner(ctx context.Context, runner *models.Runner, idempotencyKey *models.IdempotencyKey) (*models.Runner, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.CreateRunner")
+	defer span.End()
+
 	responseErr := validateRunner(runner)
 
 	if responseErr != nil {
 		return nil, responseErr
 	}
 
-	queryResult := rs.runnersRepository.QueryCreateRunner(runner)
+	var createdRunner *models.Runner
 
-	var runnerId string
-	var isActive bool
-	err := queryResult.Scan(&runnerId, &isActive)
+	responseErr = rs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		return idempotent(ctx, repos, idempotencyKey, rs.idempotencyWindow, http.StatusOK, &createdRunner, func() *models.ResponseError {
+			var responseErr *models.ResponseError
+			createdRunner, responseErr = repos.Runners.QueryCreateRunner(ctx, runner)
 
-	if err != nil {
-		return nil, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
+			if responseErr != nil {
+				return responseErr
+			}
+
+			return recordAudit(ctx, repos, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_RUNNER, createdRunner.ID, nil, createdRunner)
+		})
+	})
+
+	if responseErr != nil {
+		return nil, responseErr
 	}
 
-	return &models.Runner{
-		ID:        runnerId,
-		FirstName: runner.FirstName,
-		LastName:  runner.LastName,
-		Age:       runner.Age,
-		IsActive:  isActive,
-		Country:   runner.Country,
-	}, nil
+	return createdRunner, nil
 }
 
-func (rs RunnersService) UpdateRunner(runner *models.Runner) (int64, *models.ResponseError) {
+// UpdateRunner updates the runner if runner.Version is still their current
+// version and sets runner.Version to the new version.
+func (rs RunnersService) UpdateRunner(ctx context.Context, runner *models.Runner) (int64, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.UpdateRunner")
+	defer span.End()
+
 	responseErr := validateRunnerId(runner.ID)
 
 	if responseErr != nil {
@@ -69,133 +94,500 @@ func (rs RunnersService) UpdateRunner(runner *models.Runner) (int64, *models.Res
 		return 0, responseErr
 	}
 
-	queryResult, responseErr := rs.runnersRepository.QueryUpdateRunner(runner)
+	var rowsAffected int64
+
+	responseErr = rs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Runners.QueryGetRunnerForUpdate(ctx, runner.ID)
+
+		if responseErr != nil || before == nil {
+			return responseErr
+		}
+
+		responseErr = checkVersion(runner.Version, before.Version)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		queryResult, responseErr := repos.Runners.QueryUpdateRunner(ctx, runner)
+		rowsAffected, responseErr = rowsAffectedBy(ctx, queryResult, responseErr)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		after := *before
+		after.FirstName = runner.FirstName
+		after.LastName = runner.LastName
+		after.Age = runner.Age
+		after.Country = runner.Country
+		after.Version = before.Version + 1
+		runner.Version = after.Version
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_RUNNER, runner.ID, before, &after)
+	})
 
 	if responseErr != nil {
 		return 0, responseErr
 	}
 
-	rowsAffected, err := queryResult.RowsAffected()
+	return rowsAffected, nil
+}
 
-	if err != nil {
-		return 0, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
+// DeactivateRunner marks the runner inactive. Their results and bests are
+// kept, but they are left out of listings unless inactive runners are asked
+// for.
+func (rs RunnersService) DeactivateRunner(ctx context.Context, runnerId string, version int) (*models.Runner, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.DeactivateRunner")
+	defer span.End()
+
+	return rs.setRunnerActive(ctx, runnerId, false, version)
+}
+
+func (rs RunnersService) ReactivateRunner(ctx context.Context, runnerId string, version int) (*models.Runner, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.ReactivateRunner")
+	defer span.End()
+
+	return rs.setRunnerActive(ctx, runnerId, true, version)
+}
+
+func (rs RunnersService) setRunnerActive(ctx context.Context, runnerId string, isActive bool, version int) (*models.Runner, *models.ResponseError) {
+	responseErr := validateRunnerId(runnerId)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	var runner *models.Runner
+
+	responseErr = rs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Runners.QueryGetRunnerForUpdate(ctx, runnerId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if before == nil {
+			return runnerNotFound()
+		}
+
+		responseErr = checkVersion(version, before.Version)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if before.IsActive == isActive {
+			runner = before
+			return nil
 		}
+
+		after := *before
+		after.IsActive = isActive
+		after.Version = before.Version + 1
+		runner = &after
+
+		_, responseErr = repos.Runners.QuerySetRunnerActive(ctx, runnerId, isActive)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_RUNNER, runnerId, before, &after)
+	})
+
+	if responseErr != nil {
+		return nil, responseErr
 	}
 
-	return rowsAffected, nil
+	return runner, nil
 }
 
-func (rs RunnersService) DeleteRunner(runnerId string) (int64, *models.ResponseError) {
+// DeleteRunner deactivates the runner. With params.Purge the runner is
+// deleted for good together with their results, which needs the
+// runners:purge permission.
+func (rs RunnersService) DeleteRunner(ctx context.Context, principal *models.Principal, runnerId string, params *models.RunnerDeleteParams) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.DeleteRunner")
+	defer span.End()
+
+	purge := false
+
+	if params.Purge != "" {
+		var err error
+		purge, err = strconv.ParseBool(params.Purge)
+
+		if err != nil {
+			return &models.ResponseError{
+				Message: "Invalid purge",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "purge", Message: "Invalid purge"}},
+			}
+		}
+	}
+
+	if !purge {
+		_, responseErr := rs.setRunnerActive(ctx, runnerId, false, params.Version)
+
+		return responseErr
+	}
+
+	if principal == nil || !principal.HasPermission(models.PERMISSION_RUNNERS_PURGE) {
+		return &models.ResponseError{
+			Message: "Not authorized",
+			Status:  http.StatusForbidden,
+			Code:    models.ERROR_CODE_PERMISSION_DENIED,
+		}
+	}
+
 	responseErr := validateRunnerId(runnerId)
 
 	if responseErr != nil {
-		return 0, responseErr
+		return responseErr
 	}
 
-	queryResult, responseErr := rs.runnersRepository.QueryDeleteRunner(runnerId)
+	return rs.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		before, responseErr := repos.Runners.QueryGetRunnerForUpdate(ctx, runnerId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if before == nil {
+			return runnerNotFound()
+		}
+
+		responseErr = checkVersion(params.Version, before.Version)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		results, responseErr := repos.Results.QueryDeleteRunnersResults(ctx, runnerId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		for _, result := range results {
+			responseErr = recordAudit(ctx, repos, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_RESULT, result.ID, result, nil)
+
+			if responseErr != nil {
+				return responseErr
+			}
+		}
+
+		_, responseErr = repos.Runners.QueryDeleteRunner(ctx, runnerId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		return recordAudit(ctx, repos, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_RUNNER, runnerId, before, nil)
+	})
+}
+
+// GetRunner returns the runner with their bests. Inactive runners are only
+// returned with params.IncludeInactive.
+func (rs RunnersService) GetRunner(ctx context.Context, runnerId string, params *models.RunnerParams) (*models.Runner, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.GetRunner")
+	defer span.End()
+
+	runner, responseErr := rs.getVisibleRunner(ctx, runnerId, params.IncludeInactive)
+
+	if responseErr != nil || runner == nil {
+		return nil, responseErr
+	}
+
+	runner.Bests, responseErr = rs.runnersRepository.QueryGetRunnerBests(ctx, runnerId, rs.season.Of(time.Now()))
 
 	if responseErr != nil {
-		return 0, responseErr
+		return nil, responseErr
 	}
 
-	rowsAffected, err := queryResult.RowsAffected()
+	return runner, nil
+}
 
-	if err != nil {
-		return 0, &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
+// GetRunnersResults returns the results of the runner. params.Status is a
+// comma separated list of the statuses to return, all by default. Like
+// GetRunner it hides inactive runners unless params.IncludeInactive is set.
+func (rs RunnersService) GetRunnersResults(ctx context.Context, runnerId string, params *models.ResultsParams) ([]*models.Result, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.GetRunnersResults")
+	defer span.End()
+
+	var statuses []string
+
+	if params.Status != "" {
+		statuses = strings.Split(params.Status, ",")
+
+		for _, status := range statuses {
+			if !validation.IsResultStatus(status) {
+				return nil, &models.ResponseError{
+					Message: "Invalid status",
+					Status:  http.StatusBadRequest,
+					Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+					Fields:  []*models.FieldError{{Field: "status", Message: "Invalid status"}},
+				}
+			}
 		}
 	}
 
-	return rowsAffected, nil
+	runner, responseErr := rs.getVisibleRunner(ctx, runnerId, params.IncludeInactive)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	if runner == nil {
+		return nil, &models.ResponseError{
+			Message: "Runner not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_RUNNER_NOT_FOUND,
+		}
+	}
+
+	return rs.resultsRepository.QueryGetAllRunnersResults(ctx, runnerId, statuses)
 }
 
-func (rs RunnersService) GetRunner(runnerId string) (*models.Runner, *models.ResponseError) {
+// getVisibleRunner returns nil for unknown runners and for inactive runners
+// unless includeInactive is "true".
+func (rs RunnersService) getVisibleRunner(ctx context.Context, runnerId string, includeInactive string) (*models.Runner, *models.ResponseError) {
 	responseErr := validateRunnerId(runnerId)
 
 	if responseErr != nil {
 		return nil, responseErr
 	}
 
-	return rs.runnersRepository.QueryGetRunner(runnerId)
+	showInactive, responseErr := parseIncludeInactive(includeInactive)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	runner, responseErr := rs.runnersRepository.QueryGetRunner(ctx, runnerId)
+
+	if responseErr != nil || runner == nil {
+		return nil, responseErr
+	}
+
+	if !runner.IsActive && !showInactive {
+		return nil, nil
+	}
+
+	return runner, nil
 }
 
-func (rs RunnersService) GetRunnersResults(runnerId string) ([]*models.Result, *models.ResponseError) {
-	return rs.resultsRepository.QueryGetAllRunnersResults(runnerId)
+func (rs RunnersService) GetRunnersBatch(ctx context.Context, params *models.RunnersBatchParams) (*models.RunnersBatch, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "RunnersService.GetRunnersBatch")
+	defer span.End()
+
+	now := time.Now()
+	filter, responseErr := parseRunnersFilter(params, now.Year(), rs.season.Of(now))
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return rs.runnersRepository.QueryGetRunnersBatch(ctx, filter)
 }
 
-func (rs RunnersService) GetRunnersBatch(country string, year string) ([]*models.Runner, *models.ResponseError) {
-	if country != "" && year != "" {
-		return nil, &models.ResponseError{
-			Message: "Only one parameter can be passed",
-			Status:  http.StatusBadRequest,
-		}
+func parseRunnersFilter(params *models.RunnersBatchParams, currentYear int, season int) (*repositories.RunnersFilter, *models.ResponseError) {
+	filter := &repositories.RunnersFilter{
+		Country:  strings.TrimSpace(params.Country),
+		Distance: models.DISTANCE_MARATHON,
+		Season:   season,
+		SortBy:   repositories.SORT_PERSONAL_BEST,
+		Limit:    DEFAULT_BATCH_LIMIT,
+		Cursor:   params.Cursor,
 	}
 
-	if country != "" {
-		fmt.Println(country)
-		return rs.runnersRepository.QueryGetRunnersByCountry(country)
+	if params.Year != "" {
+		year, err := strconv.Atoi(params.Year)
+
+		if err != nil || year < 0 || year > currentYear {
+			return nil, &models.ResponseError{
+				Message: "Invalid year",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "year", Message: "Invalid year"}},
+			}
+		}
+
+		filter.Year = year
 	}
 
-	if year != "" {
-		intYear, err := strconv.Atoi(year)
+	if params.IsActive != "" {
+		isActive, err := strconv.ParseBool(params.IsActive)
 
 		if err != nil {
 			return nil, &models.ResponseError{
-				Message: "Invalid year",
+				Message: "Invalid is_active",
 				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "is_active", Message: "Invalid is_active"}},
 			}
 		}
 
-		currentYear := time.Now().Year()
+		filter.IsActive = &isActive
+	}
+
+	includeInactive, responseErr := parseIncludeInactive(params.IncludeInactive)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
 
-		if intYear < 0 || intYear > currentYear {
+	filter.IncludeInactive = includeInactive
+
+	if params.MinAge != "" {
+		minAge, err := strconv.Atoi(params.MinAge)
+
+		if err != nil || minAge < 0 {
 			return nil, &models.ResponseError{
-				Message: "Invalid year",
+				Message: "Invalid min_age",
 				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "min_age", Message: "Invalid min_age"}},
 			}
 		}
 
-		return rs.runnersRepository.QueryGetRunnersByYear(intYear)
+		filter.MinAge = minAge
 	}
 
-	return rs.runnersRepository.QueryGetAllRunners()
-}
+	if params.MaxAge != "" {
+		maxAge, err := strconv.Atoi(params.MaxAge)
 
-func validateRunner(runner *models.Runner) *models.ResponseError {
-	if strings.TrimSpace(runner.FirstName) == "" {
-		return &models.ResponseError{
-			Message: "Invalid first name",
-			Status:  http.StatusBadRequest,
+		if err != nil || maxAge < filter.MinAge {
+			return nil, &models.ResponseError{
+				Message: "Invalid max_age",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "max_age", Message: "Invalid max_age"}},
+			}
 		}
+
+		filter.MaxAge = maxAge
 	}
 
-	if strings.TrimSpace(runner.LastName) == "" {
-		return &models.ResponseError{
-			Message: "Invalid last name",
-			Status:  http.StatusBadRequest,
+	if params.Distance != "" {
+		distance, ok := models.ParseDistance(params.Distance)
+
+		if !ok {
+			return nil, &models.ResponseError{
+				Message: "Invalid distance",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "distance", Message: "Invalid distance"}},
+			}
 		}
+
+		filter.Distance = distance
 	}
 
-	if runner.Age <= 16 || runner.Age > 125 {
-		return &models.ResponseError{
-			Message: "Invalid age",
+	if params.SortBy != "" {
+		if !repositories.IsRunnersSortField(params.SortBy) {
+			return nil, &models.ResponseError{
+				Message: "Invalid sort field",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "sort", Message: "Invalid sort field"}},
+			}
+		}
+
+		filter.SortBy = params.SortBy
+	}
+
+	switch params.Order {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return nil, &models.ResponseError{
+			Message: "Invalid order",
 			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+			Fields:  []*models.FieldError{{Field: "order", Message: "Invalid order"}},
 		}
 	}
 
-	if strings.TrimSpace(runner.Country) == "" {
+	if params.Limit != "" {
+		limit, err := strconv.Atoi(params.Limit)
+
+		if err != nil || limit < 1 || limit > MAX_BATCH_LIMIT {
+			return nil, &models.ResponseError{
+				Message: "Invalid limit",
+				Status:  http.StatusBadRequest,
+				Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+				Fields:  []*models.FieldError{{Field: "limit", Message: "Invalid limit"}},
+			}
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// checkVersion fails with 412 unless expected is the current version or
+// ANY_VERSION.
+func checkVersion(expected int, current int) *models.ResponseError {
+	if expected != models.ANY_VERSION && expected != current {
 		return &models.ResponseError{
-			Message: "Invalid country",
-			Status:  http.StatusBadRequest,
+			Message: "Version does not match, reload and try again",
+			Status:  http.StatusPreconditionFailed,
+			Code:    models.ERROR_CODE_PRECONDITION_FAILED,
 		}
 	}
 
 	return nil
 }
 
+func parseIncludeInactive(value string) (bool, *models.ResponseError) {
+	if value == "" {
+		return false, nil
+	}
+
+	includeInactive, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return false, &models.ResponseError{
+			Message: "Invalid include_inactive",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_QUERY_PARAM,
+			Fields:  []*models.FieldError{{Field: "include_inactive", Message: "Invalid include_inactive"}},
+		}
+	}
+
+	return includeInactive, nil
+}
+
+func rowsAffectedBy(ctx context.Context, queryResult sql.Result, responseErr *models.ResponseError) (int64, *models.ResponseError) {
+	if responseErr != nil {
+		return 0, responseErr
+	}
+
+	rowsAffected, err := queryResult.RowsAffected()
+
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to read affected rows", "error", err)
+
+		return 0, &models.ResponseError{
+			Message: "Failed to read affected rows",
+			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return rowsAffected, nil
+}
+
+func validateRunner(runner *models.Runner) *models.ResponseError {
+	validator := validation.NewValidator()
+	validation.Runner(validator, runner)
+
+	return validator.Error(models.ERROR_CODE_INVALID_RUNNER)
+}
+
 func validateRunnerId(runnerId string) *models.ResponseError {
 	err := uuid.Validate(runnerId)
 
@@ -203,6 +595,7 @@ func validateRunnerId(runnerId string) *models.ResponseError {
 		return &models.ResponseError{
 			Message: "Invalid runner ID",
 			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_RUNNER_ID,
 		}
 	}
 
@@ -1,8 +1,10 @@
 package services
 
 import (
+	"context"
 	"net/http"
 	"runners/models"
+	"runners/repositories"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -119,3 +121,128 @@ func TestValidateRunnerIdValidUuid(t *testing.T) {
 
 	assert.Nil(t, responseErr)
 }
+
+func TestParseRunnersFilterDefaults(t *testing.T) {
+	filter, responseErr := parseRunnersFilter(&models.RunnersBatchParams{}, 2024, 2023)
+
+	assert.Nil(t, responseErr)
+	assert.Equal(t, repositories.SORT_PERSONAL_BEST, filter.SortBy)
+	assert.Equal(t, DEFAULT_BATCH_LIMIT, filter.Limit)
+	assert.Equal(t, models.DISTANCE_MARATHON, filter.Distance)
+	assert.Equal(t, 2023, filter.Season)
+	assert.Nil(t, filter.IsActive)
+}
+
+func TestParseRunnersFilterDistance(t *testing.T) {
+	for distance, meters := range map[string]int{"half_marathon": 21097, "10K": 10000, "1500": 1500} {
+		filter, responseErr := parseRunnersFilter(&models.RunnersBatchParams{Distance: distance}, 2024, 2024)
+
+		assert.Nil(t, responseErr, distance)
+		assert.Equal(t, meters, filter.Distance, distance)
+	}
+
+	_, responseErr := parseRunnersFilter(&models.RunnersBatchParams{Distance: "-5"}, 2024, 2024)
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, "Invalid distance", responseErr.Message)
+}
+
+func TestParseRunnersFilterCombined(t *testing.T) {
+	params := &models.RunnersBatchParams{
+		Country:  "Germany",
+		Year:     "2023",
+		IsActive: "true",
+		MinAge:   "20",
+		MaxAge:   "30",
+		SortBy:   "age",
+		Order:    "desc",
+		Limit:    "25",
+	}
+
+	filter, responseErr := parseRunnersFilter(params, 2024, 2024)
+
+	assert.Nil(t, responseErr)
+	assert.Equal(t, "Germany", filter.Country)
+	assert.Equal(t, 2023, filter.Year)
+	assert.True(t, *filter.IsActive)
+	assert.Equal(t, 20, filter.MinAge)
+	assert.Equal(t, 30, filter.MaxAge)
+	assert.Equal(t, repositories.SORT_AGE, filter.SortBy)
+	assert.True(t, filter.Descending)
+	assert.Equal(t, 25, filter.Limit)
+}
+
+func TestParseRunnersFilterInvalidAgeRange(t *testing.T) {
+	params := &models.RunnersBatchParams{
+		MinAge: "30",
+		MaxAge: "20",
+	}
+
+	_, responseErr := parseRunnersFilter(params, 2024, 2024)
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, "Invalid max_age", responseErr.Message)
+	assert.Equal(t, http.StatusBadRequest, responseErr.Status)
+}
+
+func TestParseRunnersFilterInvalidLimit(t *testing.T) {
+	params := &models.RunnersBatchParams{
+		Limit: "1000",
+	}
+
+	_, responseErr := parseRunnersFilter(params, 2024, 2024)
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, "Invalid limit", responseErr.Message)
+	assert.Equal(t, http.StatusBadRequest, responseErr.Status)
+}
+
+func TestParseRunnersFilterIncludeInactive(t *testing.T) {
+	filter, responseErr := parseRunnersFilter(&models.RunnersBatchParams{IncludeInactive: "true"}, 2024, 2024)
+
+	assert.Nil(t, responseErr)
+	assert.True(t, filter.IncludeInactive)
+
+	_, responseErr = parseRunnersFilter(&models.RunnersBatchParams{IncludeInactive: "sometimes"}, 2024, 2024)
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, []*models.FieldError{{Field: "include_inactive", Message: "Invalid include_inactive"}}, responseErr.Fields)
+}
+
+func TestDeleteRunnerPurgeWithoutPermission(t *testing.T) {
+	principal := &models.Principal{Permissions: []string{models.PERMISSION_RUNNERS_DELETE}}
+
+	responseErr := RunnersService{}.DeleteRunner(context.Background(), principal, "e5280c8b-093d-457a-a535-2127326cd1b2", &models.RunnerDeleteParams{
+		Purge: "true",
+	})
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, http.StatusForbidden, responseErr.Status)
+	assert.Equal(t, models.ERROR_CODE_PERMISSION_DENIED, responseErr.Code)
+}
+
+func TestGetRunnersResultsInvalidStatus(t *testing.T) {
+	_, responseErr := RunnersService{}.GetRunnersResults(context.Background(), "e5280c8b-093d-457a-a535-2127326cd1b2", &models.ResultsParams{
+		Status: "dnf,walked",
+	})
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, models.ERROR_CODE_INVALID_QUERY_PARAM, responseErr.Code)
+	assert.Equal(t, []*models.FieldError{{Field: "status", Message: "Invalid status"}}, responseErr.Fields)
+}
+
+func TestGetRunnersResultsInvalidIncludeInactive(t *testing.T) {
+	_, responseErr := RunnersService{}.GetRunnersResults(context.Background(), "e5280c8b-093d-457a-a535-2127326cd1b2", &models.ResultsParams{
+		IncludeInactive: "sometimes",
+	})
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, []*models.FieldError{{Field: "include_inactive", Message: "Invalid include_inactive"}}, responseErr.Fields)
+}
+
+func TestGetRunnersResultsInvalidRunnerId(t *testing.T) {
+	_, responseErr := RunnersService{}.GetRunnersResults(context.Background(), "not-a-uuid", &models.ResultsParams{})
+
+	assert.NotEmpty(t, responseErr)
+	assert.Equal(t, models.ERROR_CODE_INVALID_RUNNER_ID, responseErr.Code)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runners/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const TOKEN_ISSUER = "runners-app"
+
+type AccessTokenClaims struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+type TokenIssuer struct {
+	signingMethod   jwt.SigningMethod
+	signingKey      any
+	verificationKey any
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+// NewTokenIssuer supports HS256 with a shared secret of at least 32 bytes and
+// EdDSA with a base64 encoded 32 byte ed25519 seed as key.
+func NewTokenIssuer(algorithm string, key string, accessTokenTTL time.Duration, refreshTokenTTL time.Duration) (*TokenIssuer, error) {
+	if accessTokenTTL <= 0 || refreshTokenTTL <= 0 {
+		return nil, errors.New("token TTLs must be positive")
+	}
+
+	tokenIssuer := &TokenIssuer{
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if len(key) < 32 {
+			return nil, errors.New("HS256 signing key must be at least 32 bytes long")
+		}
+
+		tokenIssuer.signingMethod = jwt.SigningMethodHS256
+		tokenIssuer.signingKey = []byte(key)
+		tokenIssuer.verificationKey = []byte(key)
+	case jwt.SigningMethodEdDSA.Alg():
+		seed, err := base64.StdEncoding.DecodeString(key)
+
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("EdDSA signing key must be a base64 encoded 32 byte seed")
+		}
+
+		privateKey := ed25519.NewKeyFromSeed(seed)
+		tokenIssuer.signingMethod = jwt.SigningMethodEdDSA
+		tokenIssuer.signingKey = privateKey
+		tokenIssuer.verificationKey = privateKey.Public()
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	return tokenIssuer, nil
+}
+
+func (ti *TokenIssuer) IssueAccessToken(user *models.User, sessionId string) (string, error) {
+	now := time.Now()
+	claims := &AccessTokenClaims{
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: sessionId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    TOKEN_ISSUER,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ti.accessTokenTTL)),
+		},
+	}
+
+	return jwt.NewWithClaims(ti.signingMethod, claims).SignedString(ti.signingKey)
+}
+
+// ParseAccessToken verifies signature and expiry of the token without
+// touching the database.
+func (ti *TokenIssuer) ParseAccessToken(accessToken string) (*AccessTokenClaims, error) {
+	claims := &AccessTokenClaims{}
+	_, err := jwt.ParseWithClaims(
+		accessToken,
+		claims,
+		func(token *jwt.Token) (any, error) {
+			return ti.verificationKey, nil
+		},
+		jwt.WithValidMethods([]string{ti.signingMethod.Alg()}),
+		jwt.WithIssuer(TOKEN_ISSUER),
+		jwt.WithExpirationRequired(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// NewRefreshToken returns an opaque random token together with the hash that
+// is stored in the database in its place.
+func (ti *TokenIssuer) NewRefreshToken() (string, string, time.Time, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	refreshToken := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	return refreshToken, hashRefreshToken(refreshToken), time.Now().Add(ti.refreshTokenTTL), nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+
+	return hex.EncodeToString(hash[:])
+}
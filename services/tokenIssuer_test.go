@@ -0,0 +1,88 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"runners/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSessionId = "1f6b4a0e-2c4b-11ef-9d6a-0242ac120002"
+
+var testUser = &models.User{
+	ID:       "e5280c8b-093d-457a-a535-2127326cd1b2",
+	Username: "admin",
+	Role:     "admin",
+}
+
+func TestTokenIssuerHS256(t *testing.T) {
+	tokenIssuer, err := NewTokenIssuer("HS256", "test-signing-key-of-at-least-32-bytes", time.Minute, time.Hour)
+
+	require.NoError(t, err)
+
+	accessToken, err := tokenIssuer.IssueAccessToken(testUser, testSessionId)
+
+	require.NoError(t, err)
+
+	claims, err := tokenIssuer.ParseAccessToken(accessToken)
+
+	require.NoError(t, err)
+	assert.Equal(t, testUser.ID, claims.Subject)
+	assert.Equal(t, testUser.Role, claims.Role)
+	assert.Equal(t, testSessionId, claims.SessionID)
+}
+
+func TestTokenIssuerEdDSA(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+	tokenIssuer, err := NewTokenIssuer("EdDSA", seed, time.Minute, time.Hour)
+
+	require.NoError(t, err)
+
+	accessToken, err := tokenIssuer.IssueAccessToken(testUser, testSessionId)
+
+	require.NoError(t, err)
+
+	claims, err := tokenIssuer.ParseAccessToken(accessToken)
+
+	require.NoError(t, err)
+	assert.Equal(t, testUser.ID, claims.Subject)
+}
+
+func TestTokenIssuerExpiredToken(t *testing.T) {
+	tokenIssuer, err := NewTokenIssuer("HS256", "test-signing-key-of-at-least-32-bytes", time.Nanosecond, time.Hour)
+
+	require.NoError(t, err)
+
+	accessToken, err := tokenIssuer.IssueAccessToken(testUser, testSessionId)
+
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+	_, err = tokenIssuer.ParseAccessToken(accessToken)
+
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestTokenIssuerForeignKey(t *testing.T) {
+	tokenIssuer, _ := NewTokenIssuer("HS256", "test-signing-key-of-at-least-32-bytes", time.Minute, time.Hour)
+	otherIssuer, _ := NewTokenIssuer("HS256", "another-signing-key-of-at-least-32-bytes", time.Minute, time.Hour)
+
+	accessToken, err := otherIssuer.IssueAccessToken(testUser, testSessionId)
+
+	require.NoError(t, err)
+
+	_, err = tokenIssuer.ParseAccessToken(accessToken)
+
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+}
+
+func TestTokenIssuerShortKey(t *testing.T) {
+	_, err := NewTokenIssuer("HS256", "short", time.Minute, time.Hour)
+
+	assert.Error(t, err)
+}
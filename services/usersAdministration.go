@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"runners/models"
+	"runners/repositories"
+	"runners/tracing"
+	"runners/validation"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (us UsersService) CreateUser(ctx context.Context, newUser *models.NewUser) (*models.User, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.CreateUser")
+	defer span.End()
+
+	validator := validation.NewValidator()
+	validation.NewUser(validator, newUser)
+
+	if newUser.Role != "" {
+		exists, responseErr := us.usersRepository.QueryRoleExists(ctx, newUser.Role)
+
+		if responseErr != nil {
+			return nil, responseErr
+		}
+
+		validator.Check(exists, "user_role", "Invalid role")
+	}
+
+	responseErr := validator.Error(models.ERROR_CODE_INVALID_USER)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	passwordHash, responseErr := hashPassword(newUser.Password)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return us.usersRepository.QueryCreateUser(ctx, newUser.Username, passwordHash, newUser.Role)
+}
+
+func (us UsersService) GetUsers(ctx context.Context) ([]*models.User, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.GetUsers")
+	defer span.End()
+
+	return us.usersRepository.QueryGetAllUsers(ctx)
+}
+
+func (us UsersService) GetUserById(ctx context.Context, userId string) (*models.User, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.GetUserById")
+	defer span.End()
+
+	responseErr := validateUserId(userId)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return us.usersRepository.QueryGetUserById(ctx, userId)
+}
+
+// UpdateUser changes role and disabled flag of a user. Both invalidate the
+// sessions of the user, so the change takes effect immediately.
+func (us UsersService) UpdateUser(ctx context.Context, principal *models.Principal, userId string, update *models.UserUpdate) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.UpdateUser")
+	defer span.End()
+
+	responseErr := validateUserId(userId)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	responseErr = us.validateRole(ctx, update.Role)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	if userId == principal.UserID && (update.Disabled || update.Role != models.ROLE_ADMIN) {
+		return &models.ResponseError{
+			Message: "Admins can not disable or demote themselves",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_SELF_MODIFICATION,
+		}
+	}
+
+	return us.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		user, responseErr := repos.Users.QueryGetUserById(ctx, userId)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if user == nil {
+			return &models.ResponseError{
+				Message: "User not found",
+				Status:  http.StatusNotFound,
+				Code:    models.ERROR_CODE_USER_NOT_FOUND,
+			}
+		}
+
+		_, responseErr = repos.Users.QueryUpdateUser(ctx, userId, update)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if update.Disabled || update.Role != user.Role {
+			return repos.Sessions.QueryRevokeUserSessions(ctx, userId, "")
+		}
+
+		return nil
+	})
+}
+
+func (us UsersService) DeleteUser(ctx context.Context, principal *models.Principal, userId string) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.DeleteUser")
+	defer span.End()
+
+	responseErr := validateUserId(userId)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	if userId == principal.UserID {
+		return &models.ResponseError{
+			Message: "Admins can not delete themselves",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_SELF_MODIFICATION,
+		}
+	}
+
+	rowsAffected, responseErr := us.usersRepository.QueryDeleteUser(ctx, userId)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	if rowsAffected == 0 {
+		return &models.ResponseError{
+			Message: "User not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_USER_NOT_FOUND,
+		}
+	}
+
+	return nil
+}
+
+// ChangePassword sets a new password for the logged in user and logs out all
+// of their other sessions.
+func (us UsersService) ChangePassword(ctx context.Context, principal *models.Principal, passwordChange *models.PasswordChange) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.ChangePassword")
+	defer span.End()
+
+	return us.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		user, responseErr := repos.Users.QueryGetUserById(ctx, principal.UserID)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if user == nil {
+			return &models.ResponseError{
+				Message: "User not found",
+				Status:  http.StatusNotFound,
+				Code:    models.ERROR_CODE_USER_NOT_FOUND,
+			}
+		}
+
+		err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwordChange.CurrentPassword))
+
+		if err != nil {
+			return &models.ResponseError{
+				Message: "Invalid current password",
+				Status:  http.StatusUnauthorized,
+				Code:    models.ERROR_CODE_INVALID_CREDENTIALS,
+				Fields:  []*models.FieldError{{Field: "current_password", Message: "Invalid current password"}},
+			}
+		}
+
+		validator := validation.NewValidator()
+		validator.Check(passwordChange.NewPassword != passwordChange.CurrentPassword, "new_password", "New password must differ from the current password")
+		validation.Password(validator, "new_password", passwordChange.NewPassword, user.Username)
+
+		responseErr = validator.Error(models.ERROR_CODE_INVALID_PASSWORD)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		passwordHash, responseErr := hashPassword(passwordChange.NewPassword)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		responseErr = repos.Users.QueryUpdatePassword(ctx, user.ID, passwordHash)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		return repos.Sessions.QueryRevokeUserSessions(ctx, user.ID, principal.SessionID)
+	})
+}
+
+func validateUserId(userId string) *models.ResponseError {
+	err := uuid.Validate(userId)
+
+	if err != nil {
+		return &models.ResponseError{
+			Message: "Invalid user ID",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_USER_ID,
+		}
+	}
+
+	return nil
+}
+
+func (us UsersService) validateRole(ctx context.Context, role string) *models.ResponseError {
+	exists, responseErr := us.usersRepository.QueryRoleExists(ctx, role)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	if !exists {
+		return &models.ResponseError{
+			Message: "Invalid role",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_USER,
+			Fields:  []*models.FieldError{{Field: "user_role", Message: "Invalid role"}},
+		}
+	}
+
+	return nil
+}
+
+func hashPassword(password string) (string, *models.ResponseError) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return "", &models.ResponseError{
+			Message: "Failed to hash password",
+			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
+		}
+	}
+
+	return string(hash), nil
+}
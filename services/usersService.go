@@ -1,111 +1,330 @@
 package services
 
 import (
-	"database/sql"
-	"encoding/base64"
+	"context"
+	"errors"
 	"net/http"
+	"runners/logging"
 	"runners/models"
 	"runners/repositories"
+	"runners/tracing"
 	"strings"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// SESSION_TOUCH_INTERVAL is how often the last activity of a session is
+// recorded, so authenticating a request does not write every time.
+const SESSION_TOUCH_INTERVAL = time.Minute
+
 type UsersService struct {
-	usersRepository *repositories.UsersRepository
+	usersRepository    *repositories.UsersRepository
+	sessionsRepository *repositories.SessionsRepository
+	unitOfWork         *repositories.UnitOfWork
+	tokenIssuer        *TokenIssuer
 }
 
-func NewUsersService(usersRepository *repositories.UsersRepository) *UsersService {
+func NewUsersService(
+	usersRepository *repositories.UsersRepository,
+	sessionsRepository *repositories.SessionsRepository,
+	unitOfWork *repositories.UnitOfWork,
+	tokenIssuer *TokenIssuer) *UsersService {
 	return &UsersService{
-		usersRepository: usersRepository,
+		usersRepository:    usersRepository,
+		sessionsRepository: sessionsRepository,
+		unitOfWork:         unitOfWork,
+		tokenIssuer:        tokenIssuer,
 	}
 }
 
-func (us UsersService) GetUser(username string) (string, *models.ResponseError) {
+func (us UsersService) GetUser(ctx context.Context, username string) (*models.User, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.GetUser")
+	defer span.End()
+
 	if strings.TrimSpace(username) == "" {
-		return "", &models.ResponseError{
+		return nil, &models.ResponseError{
 			Message: "Invalid username or password",
 			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_CREDENTIALS,
 		}
 	}
 
-	queryResult := us.usersRepository.QueryGetUser(username)
+	return us.usersRepository.QueryGetUser(ctx, username)
+}
 
-	var password string
-	err := queryResult.Scan(&password)
+func (us UsersService) Logout(ctx context.Context, principal *models.Principal) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.Logout")
+	defer span.End()
 
-	switch err {
-	case nil:
-		return password, nil
-	case sql.ErrNoRows:
-		return "", nil
-	default:
-		return "", &models.ResponseError{
-			Message: err.Error(),
-			Status:  http.StatusInternalServerError,
-		}
-	}
+	return us.RevokeSession(ctx, principal, principal.SessionID)
 }
 
-func (us UsersService) Logout(accessToken string) *models.ResponseError {
+// Authenticate verifies the access token, checks that the session it was
+// issued for is still active and loads the permissions of the role.
+func (us UsersService) Authenticate(ctx context.Context, accessToken string) (*models.Principal, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.Authenticate")
+	defer span.End()
+
 	if accessToken == "" {
-		return &models.ResponseError{
+		return nil, &models.ResponseError{
 			Message: "Invalid access token",
-			Status:  http.StatusBadRequest,
+			Status:  http.StatusUnauthorized,
+			Code:    models.ERROR_CODE_INVALID_TOKEN,
 		}
 	}
 
-	return us.usersRepository.QueryRemoveAccessToken(accessToken)
-}
+	claims, err := us.tokenIssuer.ParseAccessToken(accessToken)
 
-func (us UsersService) AuthorizeUser(accessToken string, expectedRoles []string) (bool, *models.ResponseError) {
-	if accessToken == "" {
-		return false, &models.ResponseError{
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, &models.ResponseError{
+				Message: "Access token expired",
+				Status:  http.StatusUnauthorized,
+				Code:    models.ERROR_CODE_TOKEN_EXPIRED,
+			}
+		}
+
+		return nil, &models.ResponseError{
 			Message: "Invalid access token",
 			Status:  http.StatusUnauthorized,
+			Code:    models.ERROR_CODE_INVALID_TOKEN,
 		}
 	}
 
-	queryResult := us.usersRepository.QueryGetUserRole(accessToken)
+	lastSeenAt, responseErr := us.sessionsRepository.QueryGetActiveSessionLastSeen(ctx, claims.SessionID)
 
-	var role string
-	err := queryResult.Scan(&role)
+	if responseErr != nil {
+		return nil, responseErr
+	}
 
-	if err != nil {
-		return false, &models.ResponseError{
+	if lastSeenAt == nil {
+		return nil, &models.ResponseError{
 			Message: "User in not logged in",
 			Status:  http.StatusUnauthorized,
+			Code:    models.ERROR_CODE_NOT_LOGGED_IN,
+		}
+	}
+
+	if time.Since(*lastSeenAt) >= SESSION_TOUCH_INTERVAL {
+		responseErr = us.sessionsRepository.QueryTouchSession(ctx, claims.SessionID, SESSION_TOUCH_INTERVAL)
+
+		if responseErr != nil {
+			return nil, responseErr
+		}
+	}
+
+	permissions, responseErr := us.usersRepository.QueryGetRolePermissions(ctx, claims.Role)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return &models.Principal{
+		UserID:      claims.Subject,
+		Username:    claims.Username,
+		Role:        claims.Role,
+		SessionID:   claims.SessionID,
+		Permissions: permissions,
+	}, nil
+}
+
+func (us UsersService) GenerateTokens(ctx context.Context, user *models.User, userAgent string, ipAddress string) (*models.Tokens, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.GenerateTokens")
+	defer span.End()
+
+	var tokens *models.Tokens
+
+	responseErr := us.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		sessionId, responseErr := repos.Sessions.QueryCreateSession(ctx, user.ID, userAgent, ipAddress, time.Now().Add(us.tokenIssuer.refreshTokenTTL))
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		tokens, responseErr = us.issueTokens(ctx, repos, user, sessionId)
+
+		return responseErr
+	})
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	return tokens, nil
+}
+
+// RefreshTokens rotates the refresh token. Presenting a token that was already
+// rotated means it leaked, so the whole session is revoked.
+func (us UsersService) RefreshTokens(ctx context.Context, refreshToken string) (*models.Tokens, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.RefreshTokens")
+	defer span.End()
+
+	if refreshToken == "" {
+		return nil, &models.ResponseError{
+			Message: "Invalid refresh token",
+			Status:  http.StatusUnauthorized,
+			Code:    models.ERROR_CODE_INVALID_TOKEN,
+		}
+	}
+
+	var tokens *models.Tokens
+	reuseDetected := false
+
+	responseErr := us.unitOfWork.Execute(ctx, func(repos *repositories.Repositories) *models.ResponseError {
+		storedToken, responseErr := repos.Sessions.QueryGetRefreshTokenForUpdate(ctx, hashRefreshToken(refreshToken))
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if storedToken == nil || storedToken.Revoked || time.Now().After(storedToken.ExpiresAt) {
+			return &models.ResponseError{
+				Message: "Invalid refresh token",
+				Status:  http.StatusUnauthorized,
+				Code:    models.ERROR_CODE_INVALID_TOKEN,
+			}
 		}
+
+		if storedToken.Used {
+			// The revocation has to be committed, so the error is returned after the transaction
+			reuseDetected = true
+			logging.FromContext(ctx).Warn("Refresh token reuse detected, revoking session",
+				"user_id", storedToken.UserID,
+				"session_id", storedToken.SessionID)
+			_, responseErr = repos.Sessions.QueryRevokeSession(ctx, storedToken.SessionID, storedToken.UserID)
+			return responseErr
+		}
+
+		responseErr = repos.Sessions.QueryMarkRefreshTokenUsed(ctx, storedToken.ID)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		user, responseErr := repos.Users.QueryGetUserById(ctx, storedToken.UserID)
+
+		if responseErr != nil {
+			return responseErr
+		}
+
+		if user == nil || user.Disabled {
+			return &models.ResponseError{
+				Message: "Invalid refresh token",
+				Status:  http.StatusUnauthorized,
+				Code:    models.ERROR_CODE_INVALID_TOKEN,
+			}
+		}
+
+		tokens, responseErr = us.issueTokens(ctx, repos, user, storedToken.SessionID)
+
+		return responseErr
+	})
+
+	if responseErr != nil {
+		return nil, responseErr
 	}
 
-	if role == "" {
-		return false, &models.ResponseError{
-			Message: "User has no role",
+	if reuseDetected {
+		return nil, &models.ResponseError{
+			Message: "Refresh token reuse detected",
 			Status:  http.StatusUnauthorized,
+			Code:    models.ERROR_CODE_TOKEN_REUSED,
 		}
 	}
 
-	for _, expectedRole := range expectedRoles {
-		if expectedRole == role {
-			return true, nil
+	return tokens, nil
+}
+
+func (us UsersService) GetSessions(ctx context.Context, principal *models.Principal) ([]*models.Session, *models.ResponseError) {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.GetSessions")
+	defer span.End()
+
+	sessions, responseErr := us.sessionsRepository.QueryGetUserSessions(ctx, principal.UserID)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == principal.SessionID
+	}
+
+	return sessions, nil
+}
+
+func (us UsersService) RevokeSession(ctx context.Context, principal *models.Principal, sessionId string) *models.ResponseError {
+	ctx, span := tracing.StartSpan(ctx, "UsersService.RevokeSession")
+	defer span.End()
+
+	err := uuid.Validate(sessionId)
+
+	if err != nil {
+		return &models.ResponseError{
+			Message: "Invalid session ID",
+			Status:  http.StatusBadRequest,
+			Code:    models.ERROR_CODE_INVALID_SESSION_ID,
+		}
+	}
+
+	rowsAffected, responseErr := us.sessionsRepository.QueryRevokeSession(ctx, sessionId, principal.UserID)
+
+	if responseErr != nil {
+		return responseErr
+	}
+
+	if rowsAffected == 0 {
+		return &models.ResponseError{
+			Message: "Session not found",
+			Status:  http.StatusNotFound,
+			Code:    models.ERROR_CODE_SESSION_NOT_FOUND,
 		}
 	}
 
-	return false, nil
+	return nil
 }
 
-func (us UsersService) GenerateAccessToken(username string) (string, *models.ResponseError) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(username), bcrypt.DefaultCost)
+func (us UsersService) issueTokens(ctx context.Context, repos *repositories.Repositories, user *models.User, sessionId string) (*models.Tokens, *models.ResponseError) {
+	accessToken, err := us.tokenIssuer.IssueAccessToken(user, sessionId)
+
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to generate token", "error", err)
+
+		return nil, &models.ResponseError{
+			Message: "Failed to generate token",
+			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
+		}
+	}
+
+	refreshToken, refreshTokenHash, expiresAt, err := us.tokenIssuer.NewRefreshToken()
+
 	if err != nil {
-		return "", &models.ResponseError{
+		logging.FromContext(ctx).Error("Failed to generate token", "error", err)
+
+		return nil, &models.ResponseError{
 			Message: "Failed to generate token",
 			Status:  http.StatusInternalServerError,
+			Code:    models.ERROR_CODE_INTERNAL,
 		}
 	}
 
-	accessToken := base64.StdEncoding.EncodeToString(hash)
+	responseErr := repos.Sessions.QueryCreateRefreshToken(ctx, sessionId, refreshTokenHash, expiresAt)
+
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	// Every rotation keeps the session alive for as long as the new refresh token
+	responseErr = repos.Sessions.QueryExtendSession(ctx, sessionId, expiresAt)
 
-	us.usersRepository.QuerySetAccessToken(accessToken, username)
+	if responseErr != nil {
+		return nil, responseErr
+	}
 
-	return accessToken, nil
+	return &models.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
 }
@@ -1,3 +1,6 @@
+-- Schema of databases set up by hand with dbscripts/public_schema.sql and
+-- dbscripts/update_schema.sql before migrations existed, with some data
+
 SET statement_timeout = 0;
 SET lock_timeout = 0;
 SET idle_in_transaction_session_timeout = 0;
@@ -47,4 +50,33 @@ CREATE TABLE results (
 );
 
 CREATE INDEX results_year
-ON results (year);
\ No newline at end of file
+ON results (year);
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE users (
+  id uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+  username text NOT NULL UNIQUE,
+  user_password text NOT NULL,
+  user_role text NOT NULL,
+  access_token text,
+  CONSTRAINT users_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX user_access_token
+ON users (access_token);
+
+INSERT INTO users(username, user_password, user_role)
+VALUES
+  ('admin', crypt('admin', gen_salt('bf')), 'admin'),
+  ('user', crypt('user', gen_salt('bf')), 'user');
+
+INSERT INTO runners(first_name, last_name, age, country, personal_best, season_best)
+VALUES
+  ('Adam', 'Smith', 30, 'USA', '02:04:41', '02:13:13');
+
+INSERT INTO results(runner_id, race_result, location, position, year)
+SELECT
+  id, '02:13:13', 'Boston', 4, 2024
+FROM
+  runners;
@@ -0,0 +1,31 @@
+-- Accounts for the tests, production databases get their first admin from
+-- `runners-app create-admin`
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+INSERT INTO users(username, user_password, user_role)
+VALUES
+  ('admin', crypt('admin', gen_salt('bf')), 'admin'),
+  ('user', crypt('user', gen_salt('bf')), 'user');
+
+INSERT INTO runners(first_name, last_name, age, country)
+VALUES
+  ('Adam', 'Smith', 30, 'USA'),
+  ('Sarah', 'Smith', 30, 'USA'),
+  ('Max', 'Mueller', 28, 'Germany'),
+  ('Julie', 'Petit', 23, 'France');
+
+INSERT INTO runner_bests(runner_id, distance_meters, season, race_result)
+SELECT
+  runners.id, 42195, bests.season, bests.race_result::interval
+FROM
+  runners
+  JOIN (
+    VALUES
+      ('Adam', 2020, '02:04:41'),
+      ('Adam', EXTRACT(YEAR FROM CURRENT_DATE)::integer, '02:13:13'),
+      ('Sarah', EXTRACT(YEAR FROM CURRENT_DATE)::integer, '02:18:28'),
+      ('Max', 2020, '02:01:23'),
+      ('Max', EXTRACT(YEAR FROM CURRENT_DATE)::integer, '02:03:21'),
+      ('Julie', 2020, '01:55:12'),
+      ('Julie', EXTRACT(YEAR FROM CURRENT_DATE)::integer, '01:58:34')
+  ) AS bests(first_name, season, race_result) ON bests.first_name = runners.first_name;
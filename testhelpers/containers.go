@@ -2,12 +2,17 @@ package testhelpers
 
 import (
 	"context"
+	"database/sql"
+	"os"
 	"path/filepath"
+	"runners/migrations"
 	"time"
 
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+
+	_ "github.com/lib/pq"
 )
 
 type PostgresContainer struct {
@@ -15,10 +20,32 @@ type PostgresContainer struct {
 	ConnectionString string
 }
 
+// CreatePostgresContainer starts a database with all migrations applied and
+// the users and runners from testdata/seed-data.sql inserted.
 func CreatePostgresContainer(ctx context.Context) (*PostgresContainer, error) {
+	return createPostgresContainer(ctx, func(dbHandler *sql.DB) error {
+		err := migrate(ctx, dbHandler)
+
+		if err != nil {
+			return err
+		}
+
+		return execFile(ctx, dbHandler, "seed-data.sql")
+	})
+}
+
+// CreateLegacyPostgresContainer starts a database set up with the schema
+// scripts used before migrations existed, see testdata/legacy-schema.sql.
+// No migrations are applied.
+func CreateLegacyPostgresContainer(ctx context.Context) (*PostgresContainer, error) {
+	return createPostgresContainer(ctx, func(dbHandler *sql.DB) error {
+		return execFile(ctx, dbHandler, "legacy-schema.sql")
+	})
+}
+
+func createPostgresContainer(ctx context.Context, setup func(dbHandler *sql.DB) error) (*PostgresContainer, error) {
 	pgContainer, err := postgres.RunContainer(ctx,
 		testcontainers.WithImage("postgres:16.2-alpine"),
-		postgres.WithInitScripts(filepath.Join("..", "testdata", "init-db.sql")),
 		postgres.WithDatabase("runners-db"),
 		postgres.WithUsername("postgres"),
 		postgres.WithPassword("localtest"),
@@ -37,8 +64,46 @@ func CreatePostgresContainer(ctx context.Context) (*PostgresContainer, error) {
 		return nil, err
 	}
 
+	dbHandler, err := sql.Open("postgres", connStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer dbHandler.Close()
+
+	err = setup(dbHandler)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &PostgresContainer{
 		PostgresContainer: pgContainer,
 		ConnectionString:  connStr,
 	}, nil
 }
+
+func migrate(ctx context.Context, dbHandler *sql.DB) error {
+	migrator, err := migrations.NewMigrator(dbHandler)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(ctx)
+
+	return err
+}
+
+func execFile(ctx context.Context, dbHandler *sql.DB, name string) error {
+	script, err := os.ReadFile(filepath.Join("..", "testdata", name))
+
+	if err != nil {
+		return err
+	}
+
+	_, err = dbHandler.ExecContext(ctx, string(script))
+
+	return err
+}
@@ -0,0 +1,134 @@
+package validation
+
+import (
+	"regexp"
+	"runners/models"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const MIN_RUNNER_AGE = 17
+const MAX_RUNNER_AGE = 125
+const MIN_PASSWORD_LENGTH = 10
+
+// bcrypt ignores everything after the first 72 bytes of a password
+const MAX_PASSWORD_LENGTH = 72
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,50}$`)
+
+var surfaces = []string{
+	models.SURFACE_ROAD,
+	models.SURFACE_TRACK,
+	models.SURFACE_INDOOR,
+	models.SURFACE_TRAIL,
+	models.SURFACE_CROSS_COUNTRY,
+}
+
+var resultStatuses = []string{
+	models.RESULT_STATUS_FINISHED,
+	models.RESULT_STATUS_DNF,
+	models.RESULT_STATUS_DNS,
+	models.RESULT_STATUS_DQ,
+	models.RESULT_STATUS_OTL,
+}
+
+var certifications = []string{
+	models.CERTIFICATION_NONE,
+	models.CERTIFICATION_NATIONAL,
+	models.CERTIFICATION_AIMS,
+	models.CERTIFICATION_WORLD_ATHLETICS,
+}
+
+func Runner(v *Validator, runner *models.Runner) {
+	v.Required(runner.FirstName, "first_name", "Invalid first name")
+	v.Required(runner.LastName, "last_name", "Invalid last name")
+	v.Range(runner.Age, MIN_RUNNER_AGE, MAX_RUNNER_AGE, "age", "Invalid age")
+
+	if strings.TrimSpace(runner.Country) == "" {
+		v.Check(false, "country", "Invalid country")
+	} else {
+		v.Check(IsKnownCountry(runner.Country), "country", "Unknown country")
+	}
+}
+
+// Result checks a race result. Location and year are taken from the event
+// of the race and not checked here. What else a result needs depends on its
+// status: a finished result has a time and a position, a disqualification a
+// reason and a runner who did not start or finish no time.
+func Result(v *Validator, result *models.Result) {
+	v.Check(uuid.Validate(result.RunnerID) == nil, "runner_id", "Invalid Runner ID")
+	v.Check(uuid.Validate(result.RaceID) == nil, "race_id", "Invalid race ID")
+
+	switch result.Status {
+	case models.RESULT_STATUS_FINISHED:
+		v.Check(result.RaceResult > 0, "race_result", "Invalid race result")
+		v.Check(result.Position >= 1, "position", "Invalid position")
+		v.Check(result.Reason == "", "reason", "Reason is only given for results without a finish")
+	case models.RESULT_STATUS_DNF, models.RESULT_STATUS_DNS:
+		v.Check(result.RaceResult == 0, "race_result", "Race result is not given for status "+result.Status)
+		v.Check(result.Position == 0, "position", "Position is only given for finished results")
+	case models.RESULT_STATUS_DQ:
+		v.Check(result.RaceResult >= 0, "race_result", "Invalid race result")
+		v.Check(result.Position == 0, "position", "Position is only given for finished results")
+		v.Required(result.Reason, "reason", "Reason is required for disqualifications")
+	case models.RESULT_STATUS_OTL:
+		v.Check(result.RaceResult > 0, "race_result", "Invalid race result")
+		v.Check(result.Position == 0, "position", "Position is only given for finished results")
+	default:
+		v.Check(false, "status", "Unknown status")
+	}
+}
+
+func IsResultStatus(status string) bool {
+	return slices.Contains(resultStatuses, status)
+}
+
+func Event(v *Validator, event *models.Event) {
+	v.Required(event.Name, "name", "Invalid name")
+
+	_, err := time.Parse(models.DATE_FORMAT, event.Date)
+	v.Check(err == nil, "date", "Invalid date, expected YYYY-MM-DD")
+
+	v.Required(event.City, "city", "Invalid city")
+
+	if strings.TrimSpace(event.Country) == "" {
+		v.Check(false, "country", "Invalid country")
+	} else {
+		v.Check(IsKnownCountry(event.Country), "country", "Unknown country")
+	}
+}
+
+func Race(v *Validator, race *models.Race) {
+	v.Required(race.Name, "name", "Invalid name")
+	v.Check(race.DistanceMeters >= 1, "distance_meters", "Invalid distance")
+	v.Check(slices.Contains(surfaces, race.Surface), "surface", "Unknown surface")
+	v.Check(slices.Contains(certifications, race.Certification), "certification", "Unknown certification")
+}
+
+// NewUser checks everything about a new user but whether the role exists,
+// which only the database knows.
+func NewUser(v *Validator, newUser *models.NewUser) {
+	v.Check(usernamePattern.MatchString(newUser.Username), "username", "Invalid username")
+	v.Required(newUser.Role, "user_role", "Invalid role")
+	Password(v, "password", newUser.Password, newUser.Username)
+}
+
+func Password(v *Validator, field string, password string, username string) {
+	if len(password) < MIN_PASSWORD_LENGTH {
+		v.Check(false, field, "Password must be at least 10 characters long")
+		return
+	}
+
+	v.Check(len(password) <= MAX_PASSWORD_LENGTH, field, "Password must be at most 72 bytes long")
+
+	hasLetter := strings.IndexFunc(password, unicode.IsLetter) >= 0
+	hasDigit := strings.IndexFunc(password, unicode.IsDigit) >= 0
+	v.Check(hasLetter && hasDigit, field, "Password must contain letters and digits")
+
+	containsUsername := username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username))
+	v.Check(!containsUsername, field, "Password must not contain the username")
+}
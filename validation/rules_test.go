@@ -0,0 +1,214 @@
+package validation
+
+import (
+	"net/http"
+	"runners/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerReportsAllViolations(t *testing.T) {
+	validator := NewValidator()
+	Runner(validator, &models.Runner{
+		FirstName: " ",
+		Age:       12,
+		Country:   "Atlantis",
+	})
+
+	assert.Equal(t, []*models.FieldError{
+		{Field: "first_name", Message: "Invalid first name"},
+		{Field: "last_name", Message: "Invalid last name"},
+		{Field: "age", Message: "Invalid age"},
+		{Field: "country", Message: "Unknown country"},
+	}, validator.Violations())
+}
+
+func TestRunnerValid(t *testing.T) {
+	for _, country := range []string{"USA", "deu", "United States", "france"} {
+		validator := NewValidator()
+		Runner(validator, &models.Runner{
+			FirstName: "Adam",
+			LastName:  "Smith",
+			Age:       30,
+			Country:   country,
+		})
+
+		assert.True(t, validator.Valid(), country)
+	}
+}
+
+func TestResultReportsAllViolations(t *testing.T) {
+	validator := NewValidator()
+	Result(validator, &models.Result{
+		RunnerID: "1",
+		RaceID:   "berlin",
+		Status:   models.RESULT_STATUS_FINISHED,
+	})
+
+	assert.Equal(t, []*models.FieldError{
+		{Field: "runner_id", Message: "Invalid Runner ID"},
+		{Field: "race_id", Message: "Invalid race ID"},
+		{Field: "race_result", Message: "Invalid race result"},
+		{Field: "position", Message: "Invalid position"},
+	}, validator.Violations())
+}
+
+func TestResultRulesPerStatus(t *testing.T) {
+	runnerId := "e5280c8b-093d-457a-a535-2127326cd1b2"
+	raceId := "1f6b4a0e-2c4b-11ef-9d6a-0242ac120002"
+	raceResult := models.RaceTime(2*time.Hour + 30*time.Minute)
+
+	tests := []struct {
+		result     *models.Result
+		violations []*models.FieldError
+	}{
+		{
+			result: &models.Result{Status: models.RESULT_STATUS_DNS},
+		},
+		{
+			result: &models.Result{Status: models.RESULT_STATUS_DNF, RaceResult: raceResult, Position: 3},
+			violations: []*models.FieldError{
+				{Field: "race_result", Message: "Race result is not given for status dnf"},
+				{Field: "position", Message: "Position is only given for finished results"},
+			},
+		},
+		{
+			result:     &models.Result{Status: models.RESULT_STATUS_DQ},
+			violations: []*models.FieldError{{Field: "reason", Message: "Reason is required for disqualifications"}},
+		},
+		{
+			result: &models.Result{Status: models.RESULT_STATUS_DQ, Reason: "Course cutting", RaceResult: raceResult},
+		},
+		{
+			result:     &models.Result{Status: models.RESULT_STATUS_OTL},
+			violations: []*models.FieldError{{Field: "race_result", Message: "Invalid race result"}},
+		},
+		{
+			result:     &models.Result{Status: models.RESULT_STATUS_FINISHED, RaceResult: raceResult, Position: 1, Reason: "Fast"},
+			violations: []*models.FieldError{{Field: "reason", Message: "Reason is only given for results without a finish"}},
+		},
+		{
+			result:     &models.Result{Status: "walked"},
+			violations: []*models.FieldError{{Field: "status", Message: "Unknown status"}},
+		},
+	}
+
+	for _, test := range tests {
+		test.result.RunnerID = runnerId
+		test.result.RaceID = raceId
+
+		validator := NewValidator()
+		Result(validator, test.result)
+
+		if test.violations == nil {
+			assert.True(t, validator.Valid(), test.result.Status)
+		} else {
+			assert.Equal(t, test.violations, validator.Violations(), test.result.Status)
+		}
+	}
+}
+
+func TestNestedPrefixesFields(t *testing.T) {
+	validator := NewValidator()
+	validator.Check(true, "batch", "Invalid batch")
+	Result(validator.Nested("results[1]."), &models.Result{
+		RunnerID:   "e5280c8b-093d-457a-a535-2127326cd1b2",
+		Status:     models.RESULT_STATUS_FINISHED,
+		RaceResult: models.RaceTime(2*time.Hour + 5*time.Minute + 30*time.Second),
+		Position:   1,
+	})
+
+	responseErr := validator.Error(models.ERROR_CODE_INVALID_RACE_RESULT)
+
+	require.NotNil(t, responseErr)
+	assert.Equal(t, http.StatusBadRequest, responseErr.Status)
+	assert.Equal(t, "Invalid race ID", responseErr.Message)
+	assert.Equal(t, []*models.FieldError{{Field: "results[1].race_id", Message: "Invalid race ID"}}, responseErr.Fields)
+}
+
+func TestEventReportsAllViolations(t *testing.T) {
+	validator := NewValidator()
+	Event(validator, &models.Event{
+		Name:    "Berlin Marathon",
+		Date:    "29.09.2024",
+		Country: "Atlantis",
+	})
+
+	assert.Equal(t, []*models.FieldError{
+		{Field: "date", Message: "Invalid date, expected YYYY-MM-DD"},
+		{Field: "city", Message: "Invalid city"},
+		{Field: "country", Message: "Unknown country"},
+	}, validator.Violations())
+}
+
+func TestRaceReportsAllViolations(t *testing.T) {
+	validator := NewValidator()
+	Race(validator, &models.Race{
+		Name:          "Marathon",
+		Surface:       "sand",
+		Certification: models.CERTIFICATION_AIMS,
+	})
+
+	assert.Equal(t, []*models.FieldError{
+		{Field: "distance_meters", Message: "Invalid distance"},
+		{Field: "surface", Message: "Unknown surface"},
+	}, validator.Violations())
+}
+
+func TestErrorJoinsMessages(t *testing.T) {
+	validator := NewValidator()
+	validator.Required("", "first_name", "Invalid first name")
+	validator.Range(200, 17, 125, "age", "Invalid age")
+
+	responseErr := validator.Error(models.ERROR_CODE_INVALID_RUNNER)
+
+	require.NotNil(t, responseErr)
+	assert.Equal(t, "Invalid first name; Invalid age", responseErr.Message)
+	assert.Equal(t, models.ERROR_CODE_INVALID_RUNNER, responseErr.Code)
+	assert.Nil(t, NewValidator().Error(models.ERROR_CODE_INVALID_RUNNER))
+}
+
+func TestPasswordTooShort(t *testing.T) {
+	validator := NewValidator()
+	Password(validator, "password", "abc123", "runner")
+
+	assert.Equal(t, []*models.FieldError{{Field: "password", Message: "Password must be at least 10 characters long"}}, validator.Violations())
+}
+
+func TestPasswordWithoutDigits(t *testing.T) {
+	validator := NewValidator()
+	Password(validator, "password", "onlyletters", "runner")
+
+	assert.Equal(t, []*models.FieldError{{Field: "password", Message: "Password must contain letters and digits"}}, validator.Violations())
+}
+
+func TestPasswordContainsUsername(t *testing.T) {
+	validator := NewValidator()
+	Password(validator, "new_password", "Runner2024!", "runner")
+
+	assert.Equal(t, []*models.FieldError{{Field: "new_password", Message: "Password must not contain the username"}}, validator.Violations())
+}
+
+func TestPasswordValid(t *testing.T) {
+	validator := NewValidator()
+	Password(validator, "password", "marathon42k", "runner")
+
+	assert.True(t, validator.Valid())
+}
+
+func TestNewUserReportsAllViolations(t *testing.T) {
+	validator := NewValidator()
+	NewUser(validator, &models.NewUser{
+		Username: "a",
+		Password: "short1",
+	})
+
+	assert.Equal(t, []*models.FieldError{
+		{Field: "username", Message: "Invalid username"},
+		{Field: "user_role", Message: "Invalid role"},
+		{Field: "password", Message: "Password must be at least 10 characters long"},
+	}, validator.Violations())
+}
@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"net/http"
+	"runners/models"
+	"strings"
+)
+
+// Validator collects the violations of every check run on it instead of
+// stopping at the first one, so clients can fix all fields at once.
+type Validator struct {
+	prefix     string
+	violations *[]*models.FieldError
+}
+
+func NewValidator() *Validator {
+	return &Validator{
+		violations: &[]*models.FieldError{},
+	}
+}
+
+// Nested returns a validator that records its violations in v, with field
+// names prefixed by prefix, e.g. "runners[2]." for the items of a batch.
+func (v *Validator) Nested(prefix string) *Validator {
+	return &Validator{
+		prefix:     v.prefix + prefix,
+		violations: v.violations,
+	}
+}
+
+// Check records a violation of field unless ok.
+func (v *Validator) Check(ok bool, field string, message string) {
+	if ok {
+		return
+	}
+
+	*v.violations = append(*v.violations, &models.FieldError{
+		Field:   v.prefix + field,
+		Message: message,
+	})
+}
+
+func (v *Validator) Required(value string, field string, message string) {
+	v.Check(strings.TrimSpace(value) != "", field, message)
+}
+
+func (v *Validator) Range(value int, min int, max int, field string, message string) {
+	v.Check(value >= min && value <= max, field, message)
+}
+
+func (v *Validator) Valid() bool {
+	return len(*v.violations) == 0
+}
+
+func (v *Validator) Violations() []*models.FieldError {
+	return *v.violations
+}
+
+// Error returns nil if there are no violations and otherwise a bad request
+// with the given code listing all of them.
+func (v *Validator) Error(code string) *models.ResponseError {
+	if v.Valid() {
+		return nil
+	}
+
+	messages := make([]string, 0, len(*v.violations))
+
+	for _, violation := range *v.violations {
+		messages = append(messages, violation.Message)
+	}
+
+	return &models.ResponseError{
+		Message: strings.Join(messages, "; "),
+		Status:  http.StatusBadRequest,
+		Code:    code,
+		Fields:  *v.violations,
+	}
+}